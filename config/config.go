@@ -7,18 +7,37 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type AppConfig struct {
-	Env    string   `json:"env" yaml:"env"`
-	Server Server   `json:"server" yaml:"server"`
-	Logger Logger   `json:"log" yaml:"log"`
-	Room   Room     `json:"room" yaml:"room"`
+	Env    string `json:"env" yaml:"env"`
+	Server Server `json:"server" yaml:"server"`
+	Logger Logger `json:"log" yaml:"log"`
+	Store  Store  `json:"store" yaml:"store"`
+	Hold   Hold   `json:"hold" yaml:"hold"`
+	// Idempotency configures the replay of POST requests sent with an
+	// Idempotency-Key header.
+	Idempotency Idempotency `json:"idempotency" yaml:"idempotency"`
+	Events      Events      `json:"events" yaml:"events"`
+	Webhooks    Webhooks    `json:"webhooks" yaml:"webhooks"`
+	Audit       Audit       `json:"audit" yaml:"audit"`
+	History     History     `json:"history" yaml:"history"`
+	Admin       Admin       `json:"admin" yaml:"admin"`
+	Rooms       []Room      `json:"rooms" yaml:"rooms"`
+	// Room is the single room of older configs. It is read as a room with
+	// the id "default" unless it has its own id.
+	//
+	// Deprecated: declare the room in Rooms instead.
+	Room   *Room    `json:"-" yaml:"room"`
 	Groups []string `json:"groups" yaml:"groups"`
 }
 
+const legacyRoomID = "default"
+
 type Server struct {
 	Host string `json:"host" yaml:"host"`
 	Port string `json:"port" yaml:"port"`
@@ -42,9 +61,138 @@ func (l *Logger) setDefault() {
 }
 
 type Room struct {
-	NumRows     int `yaml:"num_rows"`
-	NumCols     int `yaml:"num_cols"`
-	MinDistance int `yaml:"min_distance"`
+	ID          string `json:"id" yaml:"id"`
+	NumRows     int    `json:"num_rows" yaml:"num_rows"`
+	NumCols     int    `json:"num_cols" yaml:"num_cols"`
+	MinDistance int    `json:"min_distance" yaml:"min_distance"`
+	// DistanceMetric is one of "manhattan" (default), "chebyshev",
+	// "euclidean" or "row".
+	DistanceMetric string `json:"distance_metric" yaml:"distance_metric"`
+	// Layout describes the seat map row by row: "S" is a seat, "_" an aisle
+	// and "X" a void such as a pillar or a missing seat. An empty layout
+	// means every cell is a seat.
+	Layout []string `json:"layout,omitempty" yaml:"layout"`
+	// LayoutFile is read into Layout when set, one row per line. A relative
+	// path is resolved against the directory of the config file.
+	LayoutFile string `json:"-" yaml:"layout_file"`
+	// SeparationPairs override MinDistance between the seats of two groups.
+	SeparationPairs []SeparationPair `json:"separation_pairs,omitempty" yaml:"separation_pairs"`
+	// AffinityClusters are sets of groups, such as families of a same
+	// household, that keep their own distance between each other. A pair
+	// takes precedence over a cluster.
+	AffinityClusters []AffinityCluster `json:"affinity_clusters,omitempty" yaml:"affinity_clusters"`
+}
+
+type SeparationPair struct {
+	Groups      [2]string `json:"groups" yaml:"groups"`
+	MinDistance int       `json:"min_distance" yaml:"min_distance"`
+}
+
+type AffinityCluster struct {
+	Groups []string `json:"groups" yaml:"groups"`
+	// MinDistance defaults to 0, which lets the groups sit next to each
+	// other like the seats of a single group.
+	MinDistance int `json:"min_distance" yaml:"min_distance"`
+}
+
+func (r *Room) loadLayout(dir string) error {
+	if r.LayoutFile != "" {
+		path := r.LayoutFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+
+		buf, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return fmt.Errorf("read layout file: %w", err)
+		}
+
+		r.Layout = strings.Split(strings.TrimRight(string(buf), "\n"), "\n")
+		for i := range r.Layout {
+			r.Layout[i] = strings.TrimRight(r.Layout[i], "\r")
+		}
+	}
+
+	if len(r.Layout) > 0 {
+		if r.NumRows == 0 {
+			r.NumRows = len(r.Layout)
+		}
+		if r.NumCols == 0 {
+			r.NumCols = len(r.Layout[0])
+		}
+	}
+
+	return nil
+}
+
+type Store struct {
+	// Type is either "memory" (default) or "file".
+	Type          string `json:"type" yaml:"type"`
+	Dir           string `json:"dir" yaml:"dir"`
+	SnapshotEvery int    `json:"snapshot_every" yaml:"snapshot_every"`
+}
+
+type Hold struct {
+	TTL           time.Duration `json:"ttl" yaml:"ttl"`
+	SweepInterval time.Duration `json:"sweep_interval" yaml:"sweep_interval"`
+}
+
+type Idempotency struct {
+	// TTL is how long the first response for a key is replayed, 24h by default.
+	TTL time.Duration `json:"ttl" yaml:"ttl"`
+}
+
+type Events struct {
+	// HistorySize is the number of events kept per room for clients that
+	// resume a stream, 1000 by default.
+	HistorySize int `json:"history_size" yaml:"history_size"`
+}
+
+type Webhooks struct {
+	// OutboxDir keeps pending deliveries across restarts. An empty dir keeps
+	// them in memory only.
+	OutboxDir string `json:"outbox_dir" yaml:"outbox_dir"`
+	// MaxAttempts is the number of failed attempts after which a delivery
+	// is moved to the dead letters of the outbox, 10 by default.
+	MaxAttempts    int           `json:"max_attempts" yaml:"max_attempts"`
+	InitialBackoff time.Duration `json:"initial_backoff" yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `json:"max_backoff" yaml:"max_backoff"`
+	Timeout        time.Duration `json:"timeout" yaml:"timeout"`
+	Subscriptions  []Webhook     `json:"subscriptions" yaml:"subscriptions"`
+}
+
+type Webhook struct {
+	// ID identifies the subscription of queued deliveries. Deliveries of a
+	// subscription that is removed from the config are dropped.
+	ID  string `json:"id" yaml:"id"`
+	URL string `json:"url" yaml:"url"`
+	// Events lists the event types to deliver, reservation, cancellation
+	// and change when empty. Restore and checkpoint events are never
+	// delivered.
+	Events []string `json:"events" yaml:"events"`
+	Secret string   `json:"-" yaml:"secret"`
+}
+
+type Audit struct {
+	// Dir keeps the audit log across restarts. An empty dir keeps it in
+	// memory only.
+	Dir string `json:"dir" yaml:"dir"`
+}
+
+type History struct {
+	// Dir keeps the event history across restarts. An empty dir keeps it
+	// in memory only.
+	Dir string `json:"dir" yaml:"dir"`
+	// MaxEvents is the number of events kept per room, 10000 by default.
+	// Older events are folded into a checkpoint of the room, so states
+	// before the checkpoint can no longer be rebuilt.
+	MaxEvents int `json:"max_events" yaml:"max_events"`
+}
+
+type Admin struct {
+	// Token must be sent as a bearer token to the /admin endpoints, which
+	// are disabled when it is empty. The ADMIN_TOKEN env overrides it.
+	Token string `json:"-" yaml:"token"`
 }
 
 type setDefaulter interface {
@@ -79,6 +227,28 @@ func Read(getEnv func(string) string) (empty AppConfig, err error) {
 		}
 	}
 
+	if appCfg.Room != nil {
+		if len(appCfg.Rooms) > 0 {
+			return empty, errors.New("room and rooms are both set, move the room block into rooms")
+		}
+		if appCfg.Room.ID == "" {
+			appCfg.Room.ID = legacyRoomID
+		}
+		slog.Warn("the room block is deprecated, declare the room in rooms instead", "room_id", appCfg.Room.ID)
+		appCfg.Rooms = []Room{*appCfg.Room}
+		appCfg.Room = nil
+	}
+
+	for i := range appCfg.Rooms {
+		if err = appCfg.Rooms[i].loadLayout(filepath.Dir(filename)); err != nil {
+			return empty, fmt.Errorf("room %s: %w", appCfg.Rooms[i].ID, err)
+		}
+	}
+
+	if token := getEnv("ADMIN_TOKEN"); token != "" {
+		appCfg.Admin.Token = token
+	}
+
 	if appCfg.Env == "" {
 		appCfg.Env = "local"
 	}
@@ -1,3 +1,12 @@
 package ctxkey
 
 type RequestID struct{}
+
+// ExpectedVersions holds the room versions a mutation is conditional on.
+type ExpectedVersions struct{}
+
+// ReportedVersion holds where a mutation stores the room version it leaves.
+type ReportedVersion struct{}
+
+// Actor names the caller of a request, as reported by the caller.
+type Actor struct{}
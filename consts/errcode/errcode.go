@@ -4,6 +4,10 @@ const (
 	Success = iota
 
 	InvalidParameters
+	ResourceNotFound
+	ResourceConflict
+	InternalError
+	Unauthorized
 )
 
 func Text(code int) string {
@@ -12,6 +16,14 @@ func Text(code int) string {
 		return "Success"
 	case InvalidParameters:
 		return "Invalid parameters"
+	case ResourceNotFound:
+		return "Resource not found"
+	case ResourceConflict:
+		return "Resource conflict"
+	case InternalError:
+		return "Internal error"
+	case Unauthorized:
+		return "Unauthorized"
 	default:
 		return ""
 	}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/namlh/vulcanLabsOA/consts/errcode"
+	"github.com/namlh/vulcanLabsOA/manager"
+)
+
+var auditOperations = []string{
+	manager.AuditOperationReserve,
+	manager.AuditOperationCancel,
+	manager.AuditOperationHold,
+	manager.AuditOperationMove,
+	manager.AuditOperationTransaction,
+	manager.AuditOperationReconfigure,
+	manager.AuditOperationRelease,
+}
+
+type AuditController struct {
+	logger *slog.Logger
+	audit  manager.AuditLog
+}
+
+func NewAuditController(logger *slog.Logger, audit manager.AuditLog) *AuditController {
+	return &AuditController{
+		logger: logger,
+		audit:  audit,
+	}
+}
+
+type AuditPage struct {
+	Entries []manager.AuditEntry `json:"entries"`
+	// NextCursor is passed as the cursor parameter to get the next page.
+	// It is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func (c *AuditController) ListEntries(w http.ResponseWriter, r *http.Request) {
+	easyHandler("list audit entries", w, r, c.logger, func(ctx context.Context) (AuditPage, error) {
+		query, problems := parseAuditQuery(r.URL.Query())
+		if len(problems) > 0 {
+			return AuditPage{}, AppError{
+				ErrCode:    errcode.InvalidParameters,
+				HttpStatus: http.StatusUnprocessableEntity,
+				err:        problems,
+			}
+		}
+
+		entries, more, err := c.audit.Query(ctx, query)
+		if err != nil {
+			return AuditPage{}, fmt.Errorf("query audit log: %w", err)
+		}
+
+		page := AuditPage{Entries: entries}
+		if page.Entries == nil {
+			page.Entries = []manager.AuditEntry{}
+		}
+		if more {
+			page.NextCursor = strconv.FormatUint(entries[len(entries)-1].ID, 10)
+		}
+
+		return page, nil
+	})
+}
+
+func parseAuditQuery(values url.Values) (manager.AuditQuery, ValidationErrors) {
+	problems := make(ValidationErrors)
+	query := manager.AuditQuery{
+		RoomID:    values.Get("room_id"),
+		GroupID:   values.Get("group_id"),
+		Operation: values.Get("operation"),
+	}
+
+	if query.Operation != "" && !slices.Contains(auditOperations, query.Operation) {
+		problems["operation"] = fmt.Sprintf("operation must be one of %v", auditOperations)
+	}
+
+	parseTime := func(key string) time.Time {
+		v := values.Get(key)
+		if v == "" {
+			return time.Time{}
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			problems[key] = key + " must be an RFC 3339 time"
+		}
+		return t
+	}
+	query.From = parseTime("from")
+	query.To = parseTime("to")
+
+	parseInt := func(key string) *int {
+		v := values.Get(key)
+		if v == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			problems[key] = key + " must be a non-negative integer"
+			return nil
+		}
+		return &n
+	}
+	query.Row = parseInt("row")
+	query.Col = parseInt("col")
+	if limit := parseInt("limit"); limit != nil {
+		query.Limit = *limit
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		after, err := strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			problems["cursor"] = "invalid cursor"
+		}
+		query.After = after
+	}
+
+	return query, problems
+}
@@ -0,0 +1,106 @@
+package controller_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/namlh/vulcanLabsOA/config"
+	"github.com/namlh/vulcanLabsOA/controller"
+	"github.com/namlh/vulcanLabsOA/manager"
+	"github.com/namlh/vulcanLabsOA/testing/assert"
+)
+
+func TestAuditController_ListEntries(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	srv := newTestServer(t, testRoom{audit: manager.NewMemoryAuditLog(), actor: "box-office"})
+
+	status, _ := doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"abc","position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"xyz","position":[0,1]}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/cancellation", `{"seats_cancellation":[{"position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, body := doRequest(t, ctx, "GET", srv.URL+"/api/v1/audit", "")
+	assert.Equal(t, http.StatusOK, status)
+	page := decodeData[controller.AuditPage](t, body)
+	assert.Equal(t, 3, len(page.Entries))
+	assert.Equal(t, "", page.NextCursor)
+	assert.Equal(t, manager.AuditOperationReserve, page.Entries[0].Operation)
+	assert.Equal(t, manager.AuditResultSuccess, page.Entries[0].Result)
+	assert.Equal(t, "box-office", page.Entries[0].Actor)
+	assert.Equal(t, "main", page.Entries[0].RoomID)
+	assert.Equal(t, manager.AuditResultFailure, page.Entries[1].Result)
+	assert.Equal(t, "position [0,1] at index 0 violate min distance constraint", page.Entries[1].Error)
+
+	// the cancelled seat is recorded with the group it belonged to
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/audit?operation=cancel&group_id=abc&row=0&col=0", "")
+	assert.Equal(t, http.StatusOK, status)
+	page = decodeData[controller.AuditPage](t, body)
+	assert.Equal(t, 1, len(page.Entries))
+	assert.Equal(t, uint64(3), page.Entries[0].ID)
+	assert.Equal(t, manager.Seat{GroupID: "abc", Coordinate: manager.Coordinate{0, 0}}, page.Entries[0].Seats[0])
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/audit?group_id=xyz&to=2000-01-01T00:00:00Z", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"code":0,"message":"Success","data":{"entries":[]}}`, body)
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/audit?limit=2", "")
+	assert.Equal(t, http.StatusOK, status)
+	page = decodeData[controller.AuditPage](t, body)
+	assert.Equal(t, 2, len(page.Entries))
+	assert.Equal(t, "2", page.NextCursor)
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/audit?limit=2&cursor="+page.NextCursor, "")
+	assert.Equal(t, http.StatusOK, status)
+	page = decodeData[controller.AuditPage](t, body)
+	assert.Equal(t, 1, len(page.Entries))
+	assert.Equal(t, uint64(3), page.Entries[0].ID)
+	assert.Equal(t, "", page.NextCursor)
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/audit?operation=steal&from=yesterday", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","details":{"from":"from must be an RFC 3339 time","operation":"operation must be one of [reserve cancel hold move transaction reconfigure release]"}}`, body)
+}
+
+func TestAuditController_ExpiredHolds(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	srv := newTestServer(t, testRoom{
+		hold:     config.Hold{TTL: time.Millisecond},
+		groupIDs: []string{"abc"},
+		audit:    manager.NewMemoryAuditLog(),
+		actor:    "box-office",
+	})
+
+	status, body := doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/hold", `{"seats_hold":[{"group_id":"abc","position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+	confirmed := decodeData[manager.Hold](t, body)
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/hold", `{"seats_hold":[{"group_id":"abc","position":[3,3]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+	time.Sleep(5 * time.Millisecond)
+
+	// one hold is found expired by its confirmation, the other by the sweeper
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/holds/"+confirmed.ID+"/confirm", "")
+	assert.Equal(t, http.StatusGone, status)
+	room, err := srv.rooms.GetRoom(ctx, "main")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, room.ReleaseExpiredHolds(ctx))
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/audit?operation=release", "")
+	assert.Equal(t, http.StatusOK, status)
+	page := decodeData[controller.AuditPage](t, body)
+	assert.Equal(t, 2, len(page.Entries))
+	assert.Equal(t, confirmed.ID, page.Entries[0].HoldID)
+	assert.Equal(t, manager.Coordinate{0, 0}, page.Entries[0].Seats[0].Coordinate)
+	assert.Equal(t, manager.Coordinate{3, 3}, page.Entries[1].Seats[0].Coordinate)
+	for _, entry := range page.Entries {
+		assert.Equal(t, manager.AuditActorSweeper, entry.Actor)
+		assert.Equal(t, manager.AuditResultSuccess, entry.Result)
+	}
+}
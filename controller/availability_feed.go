@@ -0,0 +1,322 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/namlh/vulcanLabsOA/manager"
+)
+
+const feedClientBufferSize = 64
+
+// availabilityFeed follows the events of a room and computes once per
+// change the seats that became available or unavailable around the seats
+// it touched. The encoded update is shared by every client of the room.
+type availabilityFeed struct {
+	logger *slog.Logger
+	roomID string
+	room   manager.RoomManager
+	events *manager.EventBus
+	cancel context.CancelFunc
+
+	mu      *sync.Mutex
+	clients map[chan []byte]struct{}
+	// available holds the available seats per group, as of version. Only
+	// the feed goroutine, or join before starting it, changes it, so the
+	// feed goroutine reads it without the lock.
+	available map[string]map[manager.Coordinate]struct{}
+	version   uint64
+	// loaded is the version of the last full listing, which already covers
+	// the events up to it
+	loaded uint64
+}
+
+// join returns the feed of roomID and a channel that first receives the
+// full availability of the room, then its updates. The channel is closed
+// when the client falls behind.
+func (c *WebSocketController) join(roomID string, room manager.RoomManager) (*availabilityFeed, chan []byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	feed := c.feeds[roomID]
+	if feed == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		feed = &availabilityFeed{
+			logger:  c.logger,
+			roomID:  roomID,
+			room:    room,
+			events:  c.events,
+			cancel:  cancel,
+			mu:      new(sync.Mutex),
+			clients: make(map[chan []byte]struct{}),
+		}
+		// subscribed before listing so that no change is missed
+		sub := c.events.Subscribe(roomID)
+		if err := feed.load(ctx); err != nil {
+			c.events.Unsubscribe(sub)
+			cancel()
+			return nil, nil, err
+		}
+		go feed.run(ctx, sub)
+		c.feeds[roomID] = feed
+	}
+
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+
+	msg, err := json.Marshal(WSAvailability{
+		Type:    wsMessageAvailability,
+		RoomID:  roomID,
+		Version: feed.version,
+		Full:    true,
+		Added:   feed.listAvailable(),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("encode message: %w", err)
+	}
+	ch := make(chan []byte, feedClientBufferSize)
+	ch <- msg
+	feed.clients[ch] = struct{}{}
+
+	return feed, ch, nil
+}
+
+// leave stops the feed once its last client is gone.
+func (c *WebSocketController) leave(feed *availabilityFeed, ch chan []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+
+	delete(feed.clients, ch)
+	if len(feed.clients) == 0 && c.feeds[feed.roomID] == feed {
+		feed.cancel()
+		delete(c.feeds, feed.roomID)
+	}
+}
+
+func (f *availabilityFeed) run(ctx context.Context, sub *manager.Subscription) {
+	defer func() {
+		// sub is replaced when the feed falls behind
+		f.events.Unsubscribe(sub)
+	}()
+
+	events := sub.C()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				// fell behind the event bus
+				sub = f.events.Subscribe(f.roomID)
+				events = sub.C()
+				f.reload(ctx)
+				continue
+			}
+			if event.Version <= f.loaded {
+				continue
+			}
+
+			// coalesce the changes that are already queued
+			full := false
+			version := event.Version
+			seats := touchedSeats(event, &full)
+			for drained := false; !drained; {
+				select {
+				case event, ok = <-events:
+					if !ok {
+						drained = true
+						continue
+					}
+					version = max(version, event.Version)
+					seats = append(seats, touchedSeats(event, &full)...)
+				default:
+					drained = true
+				}
+			}
+
+			if full {
+				f.reload(ctx)
+				continue
+			}
+			f.update(ctx, seats, version)
+		}
+	}
+}
+
+// touchedSeats returns the seats taken or freed by event, and sets full
+// when the event changes the whole room.
+func touchedSeats(event manager.Event, full *bool) []manager.Coordinate {
+	switch event.Type {
+	case manager.EventRestore, manager.EventReconfiguration, manager.EventCheckpoint:
+		*full = true
+		return nil
+	}
+
+	var seats []manager.Coordinate
+	for _, reservation := range event.Reserved {
+		seats = append(seats, reservation.Coordinate)
+	}
+	for _, reservation := range event.Cancelled {
+		seats = append(seats, reservation.Coordinate)
+	}
+	if event.Hold != nil {
+		for _, seat := range event.Hold.Seats {
+			seats = append(seats, seat.Coordinate)
+		}
+	}
+
+	return seats
+}
+
+// load lists every available seat.
+func (f *availabilityFeed) load(ctx context.Context) error {
+	seats, version, err := f.room.ListAvailableSeats(ctx, "")
+	if err != nil {
+		return fmt.Errorf("list available seats: %w", err)
+	}
+
+	f.available, f.version, f.loaded = toSeatSets(seats), version, version
+
+	return nil
+}
+
+// reload lists every available seat again and sends the difference.
+func (f *availabilityFeed) reload(ctx context.Context) {
+	seats, version, err := f.room.ListAvailableSeats(ctx, "")
+	if err != nil {
+		f.logger.ErrorContext(ctx, "reload availability", "room_id", f.roomID, "error", err)
+		return
+	}
+
+	previous := f.listAvailable()
+	buf, err := f.encode(WSAvailability{
+		Type:    wsMessageAvailability,
+		RoomID:  f.roomID,
+		Version: version,
+		Added:   diffSeats(seats, previous),
+		Removed: diffSeats(previous, seats),
+	})
+	if err != nil {
+		f.logger.ErrorContext(ctx, "encode availability", "room_id", f.roomID, "error", err)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.available, f.version, f.loaded = toSeatSets(seats), version, version
+	f.send(buf)
+}
+
+// update compares the availability around seats with the known one and
+// sends the difference.
+func (f *availabilityFeed) update(ctx context.Context, seats []manager.Coordinate, version uint64) {
+	area := f.room.ListAvailableSeatsAround(ctx, seats)
+	if !slices.Equal(slices.Sorted(maps.Keys(area.Available)), slices.Sorted(maps.Keys(f.available))) {
+		// the groups changed, so the availability of the new ones is unknown
+		f.reload(ctx)
+		return
+	}
+
+	msg := WSAvailability{
+		Type:    wsMessageAvailability,
+		RoomID:  f.roomID,
+		Version: max(f.version, version),
+		Added:   make(map[string][]manager.Coordinate),
+		Removed: make(map[string][]manager.Coordinate),
+	}
+	for groupID, available := range f.available {
+		areaAvailable := area.Available[groupID]
+		for _, seat := range area.Seats {
+			_, was := available[seat]
+			_, is := slices.BinarySearchFunc(areaAvailable, seat, compareCoordinate)
+			if is && !was {
+				msg.Added[groupID] = append(msg.Added[groupID], seat)
+			} else if was && !is {
+				msg.Removed[groupID] = append(msg.Removed[groupID], seat)
+			}
+		}
+	}
+	buf, err := f.encode(msg)
+	if err != nil {
+		f.logger.ErrorContext(ctx, "encode availability", "room_id", f.roomID, "error", err)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for groupID, seats := range msg.Added {
+		for _, seat := range seats {
+			f.available[groupID][seat] = struct{}{}
+		}
+	}
+	for groupID, seats := range msg.Removed {
+		for _, seat := range seats {
+			delete(f.available[groupID], seat)
+		}
+	}
+	f.version = msg.Version
+	f.send(buf)
+}
+
+// encode returns nil when msg holds no change.
+func (f *availabilityFeed) encode(msg WSAvailability) ([]byte, error) {
+	if len(msg.Added) == 0 && len(msg.Removed) == 0 {
+		return nil, nil
+	}
+
+	buf, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("encode message: %w", err)
+	}
+
+	return buf, nil
+}
+
+// send queues buf for every client. It is called with the lock held,
+// together with the change of the availability, so that a client joining
+// meanwhile does not receive a change twice. A client whose queue is full
+// is dropped and has to join again.
+func (f *availabilityFeed) send(buf []byte) {
+	if buf == nil {
+		return
+	}
+
+	for ch := range f.clients {
+		select {
+		case ch <- buf:
+		default:
+			delete(f.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+// listAvailable returns the available seats per group in row-major order.
+func (f *availabilityFeed) listAvailable() map[string][]manager.Coordinate {
+	seats := make(map[string][]manager.Coordinate, len(f.available))
+	for groupID, available := range f.available {
+		seats[groupID] = slices.SortedFunc(maps.Keys(available), compareCoordinate)
+	}
+
+	return seats
+}
+
+func toSeatSets(seats map[string][]manager.Coordinate) map[string]map[manager.Coordinate]struct{} {
+	sets := make(map[string]map[manager.Coordinate]struct{}, len(seats))
+	for groupID, coords := range seats {
+		set := make(map[manager.Coordinate]struct{}, len(coords))
+		for _, coord := range coords {
+			set[coord] = struct{}{}
+		}
+		sets[groupID] = set
+	}
+
+	return sets
+}
@@ -11,6 +11,7 @@ import (
 	"net/http"
 
 	"github.com/namlh/vulcanLabsOA/consts/errcode"
+	"github.com/namlh/vulcanLabsOA/controller/request"
 )
 
 type Validator interface {
@@ -20,6 +21,12 @@ type Validator interface {
 	Valid(ctx context.Context) map[string]string
 }
 
+// ItemValidator is implemented by requests carrying a list of items, whose
+// problems are reported per item in the same shape as the seat errors.
+type ItemValidator interface {
+	ValidItems(ctx context.Context) []request.ItemProblem
+}
+
 func encode[T any](w http.ResponseWriter, status int, v T) error {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -60,16 +67,34 @@ func decodeValid[T Validator](r *http.Request) (T, error) {
 		return v, fmt.Errorf("close body: %w", err)
 	}
 
-	problems := v.Valid(r.Context())
-	if len(problems) > 0 {
-		return v, AppError{
-			ErrCode:    errcode.InvalidParameters,
-			HttpStatus: http.StatusUnprocessableEntity,
-			err:        ValidationErrors(problems),
+	return v, validate(r.Context(), v)
+}
+
+// validate reports both the problems of the fields and those of the items
+// of v.
+func validate(ctx context.Context, v Validator) error {
+	var err error
+	if problems := v.Valid(ctx); len(problems) > 0 {
+		err = ValidationErrors(problems)
+	}
+	if iv, ok := v.(ItemValidator); ok {
+		if problems := iv.ValidItems(ctx); len(problems) > 0 {
+			fErrs := make(FieldErrors, len(problems))
+			for i, problem := range problems {
+				fErrs[i] = FieldError(problem)
+			}
+			err = errors.Join(err, fErrs)
 		}
 	}
+	if err == nil {
+		return nil
+	}
 
-	return v, nil
+	return AppError{
+		ErrCode:    errcode.InvalidParameters,
+		HttpStatus: http.StatusUnprocessableEntity,
+		err:        err,
+	}
 }
 
 type AppError struct {
@@ -87,6 +112,7 @@ type ErrResponse struct {
 	Code    int               `json:"code"`
 	Message string            `json:"message"`
 	Details map[string]string `json:"details,omitempty"`
+	Errors  []FieldError      `json:"errors,omitempty"`
 }
 
 type SuccessResponse[T any] struct {
@@ -117,6 +143,28 @@ func (e ValidationErrors) Error() string {
 	return ""
 }
 
+// FieldError describes a problem with one item of a list in the request.
+type FieldError struct {
+	Index   int    `json:"index"`
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	var err error
+	for _, fErr := range e {
+		err = errors.Join(err, fmt.Errorf("%d.%s:%s", fErr.Index, fErr.Field, fErr.Message))
+	}
+	if err != nil {
+		return err.Error()
+	}
+
+	return ""
+}
+
 func handleError(
 	ctx context.Context,
 	logger *slog.Logger,
@@ -127,19 +175,7 @@ func handleError(
 	logger.ErrorContext(ctx, msg, "error", err)
 
 	if appErr := (AppError{}); errors.As(err, &appErr) {
-		resp := ErrResponse{
-			Code:    appErr.ErrCode,
-			Message: appErr.Message,
-		}
-		if resp.Message == "" {
-			resp.Message = errcode.Text(appErr.ErrCode)
-		}
-
-		if vErr := (ValidationErrors{}); errors.As(appErr.err, &vErr) {
-			resp.Details = vErr
-		}
-
-		if err = encode(w, appErr.HttpStatus, resp); err != nil {
+		if err = encode(w, appErr.HttpStatus, newErrResponse(appErr)); err != nil {
 			logger.ErrorContext(ctx, "encode failed", "error", err)
 			internalErrResponse(w)
 		}
@@ -150,6 +186,25 @@ func handleError(
 	internalErrResponse(w)
 }
 
+func newErrResponse(appErr AppError) ErrResponse {
+	resp := ErrResponse{
+		Code:    appErr.ErrCode,
+		Message: appErr.Message,
+	}
+	if resp.Message == "" {
+		resp.Message = errcode.Text(appErr.ErrCode)
+	}
+
+	if vErr := (ValidationErrors{}); errors.As(appErr.err, &vErr) {
+		resp.Details = vErr
+	}
+	if fErrs := (FieldErrors{}); errors.As(appErr.err, &fErrs) {
+		resp.Errors = fErrs
+	}
+
+	return resp
+}
+
 func internalErrResponse(w http.ResponseWriter) {
 	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
@@ -166,6 +221,10 @@ func easyHandler[T any](
 	ctx := r.Context()
 
 	data, err := f(ctx)
+	if errors.Is(err, errNotModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	if err != nil {
 		handleError(ctx, logger, name, w, err)
 		return
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/namlh/vulcanLabsOA/manager"
+)
+
+// errNotModified makes easyHandler answer 304 without a body.
+var errNotModified = AppError{HttpStatus: http.StatusNotModified, err: errors.New("not modified")}
+
+// formatETag tags the available seats of a room with its version and the
+// generation of the group set, as both change the listing.
+func formatETag(version, generation uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + "-" + strconv.FormatUint(generation, 10) + `"`
+}
+
+// etagMatch reports whether header, an If-Match or If-None-Match value,
+// matches etag. Weak tags only match when weak is set.
+func etagMatch(header string, etag string, weak bool) bool {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withIfMatch makes the mutations done with the returned context
+// conditional on the If-Match header of r. Only the room version of a tag
+// is checked, a change of the group set does not conflict with a mutation.
+func withIfMatch(ctx context.Context, r *http.Request) context.Context {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return ctx
+	}
+
+	versions := make([]uint64, 0)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) || len(tag) < 2 {
+			continue
+		}
+		value, _, _ := strings.Cut(tag[1:len(tag)-1], "-")
+		version, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, version)
+	}
+
+	return manager.WithExpectedVersions(ctx, versions)
+}
+
+// conditional makes the mutations done with the returned context
+// conditional on the If-Match header of r. The returned func sets on w the
+// ETag of the room state the mutations left.
+func (c *RoomController) conditional(ctx context.Context, w http.ResponseWriter, r *http.Request) (context.Context, func()) {
+	generation := c.groupManager.Generation(ctx)
+	var version uint64
+	ctx = manager.WithVersionReport(withIfMatch(ctx, r), &version)
+
+	return ctx, func() { w.Header().Set("ETag", formatETag(version, generation)) }
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/namlh/vulcanLabsOA/consts/errcode"
+	"github.com/namlh/vulcanLabsOA/manager"
+)
+
+const eventHeartbeatInterval = 15 * time.Second
+
+type EventController struct {
+	logger *slog.Logger
+	rooms  manager.RoomRegistry
+	events *manager.EventBus
+}
+
+func NewEventController(logger *slog.Logger, rooms manager.RoomRegistry, events *manager.EventBus) *EventController {
+	return &EventController{
+		logger: logger,
+		rooms:  rooms,
+		events: events,
+	}
+}
+
+// StreamEvents streams the changes of a room as Server-Sent Events. The id
+// of each event is the room version after the change, and a client that
+// reconnects with Last-Event-ID receives the events it missed, or a reset
+// event when they are no longer retained.
+func (c *EventController) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	roomID := r.URL.Query().Get("room_id")
+	if roomID == "" {
+		handleError(ctx, c.logger, "stream events", w, AppError{
+			ErrCode:    errcode.InvalidParameters,
+			HttpStatus: http.StatusUnprocessableEntity,
+			err:        ValidationErrors{"room_id": "room_id must not be empty"},
+		})
+		return
+	}
+	if _, err := c.rooms.GetRoom(ctx, roomID); err != nil {
+		handleError(ctx, c.logger, "stream events", w, roomAppError(err, roomID))
+		return
+	}
+
+	var (
+		sub     *manager.Subscription
+		backlog []manager.Event
+		reset   bool
+	)
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		version, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			handleError(ctx, c.logger, "stream events", w, AppError{
+				ErrCode:    errcode.InvalidParameters,
+				HttpStatus: http.StatusBadRequest,
+				Message:    "Last-Event-ID must be a room version",
+				err:        err,
+			})
+			return
+		}
+
+		var ok bool
+		sub, backlog, ok = c.events.SubscribeAfter(roomID, version)
+		reset = !ok
+	} else {
+		sub = c.events.Subscribe(roomID)
+	}
+	defer c.events.Unsubscribe(sub)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if reset {
+		if _, err := fmt.Fprint(w, "event: reset\ndata: {}\n\n"); err != nil {
+			return
+		}
+	}
+	for _, event := range backlog {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		c.logger.ErrorContext(ctx, "flush events", "error", err)
+		return
+	}
+
+	ticker := time.NewTicker(eventHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.C():
+			if !ok {
+				// the subscriber fell behind, let the client resume
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event manager.Event) error {
+	buf, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Version, event.Type, buf)
+
+	return err
+}
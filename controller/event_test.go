@@ -0,0 +1,86 @@
+package controller_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/namlh/vulcanLabsOA/manager"
+	"github.com/namlh/vulcanLabsOA/testing/assert"
+)
+
+func TestEventController_StreamEvents(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	srv := newTestServer(t, testRoom{eventBus: manager.NewEventBus(2)})
+
+	status, body := doRequest(t, ctx, "GET", srv.URL+"/api/v1/events?room_id=foo", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, `{"code":2,"message":"room \"foo\" not found"}`, body)
+
+	stream := func(lastEventID string) *bufio.Reader {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, "GET", srv.URL+"/api/v1/events?room_id=main", nil)
+		assert.NoError(t, err)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		return bufio.NewReader(resp.Body)
+	}
+	// next returns the id, type and data of the next event
+	next := func(r *bufio.Reader) (string, string, string) {
+		t.Helper()
+		fields := make(map[string]string)
+		for {
+			line, err := r.ReadString('\n')
+			assert.NoError(t, err)
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" {
+				return fields["id"], fields["event"], fields["data"]
+			}
+			key, value, _ := strings.Cut(line, ": ")
+			fields[key] = value
+		}
+	}
+
+	events := stream("")
+
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"abc","position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/cancellation", `{"seats_cancellation":[{"position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	id, typ, data := next(events)
+	assert.Equal(t, manager.EventReservation, typ)
+	assert.Equal(t, true, strings.Contains(data, `"reserved":[{"group_id":"abc","position":[0,0]`))
+	reservedID, err := strconv.ParseUint(id, 10, 64)
+	assert.NoError(t, err)
+
+	id, typ, data = next(events)
+	assert.Equal(t, strconv.FormatUint(reservedID+1, 10), id)
+	assert.Equal(t, manager.EventCancellation, typ)
+	assert.Equal(t, true, strings.Contains(data, `"cancelled":[{"group_id":"abc","position":[0,0]`))
+
+	// resume after the reservation
+	id, typ, _ = next(stream(strconv.FormatUint(reservedID, 10)))
+	assert.Equal(t, strconv.FormatUint(reservedID+1, 10), id)
+	assert.Equal(t, manager.EventCancellation, typ)
+
+	_, typ, _ = next(stream(strconv.FormatUint(reservedID-1, 10)))
+	assert.Equal(t, manager.EventReservation, typ)
+
+	// only the last two events are retained
+	_, typ, _ = next(stream(strconv.FormatUint(reservedID-2, 10)))
+	assert.Equal(t, "reset", typ)
+}
@@ -2,21 +2,28 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 
+	"github.com/namlh/vulcanLabsOA/consts/errcode"
+	"github.com/namlh/vulcanLabsOA/controller/request"
 	"github.com/namlh/vulcanLabsOA/manager"
 )
 
 type GroupController struct {
 	logger  *slog.Logger
 	manager manager.GroupManager
+	rooms   manager.RoomRegistry
 }
 
-func NewGroupController(logger *slog.Logger, manager manager.GroupManager) *GroupController {
+func NewGroupController(logger *slog.Logger, manager manager.GroupManager, rooms manager.RoomRegistry) *GroupController {
 	return &GroupController{
 		logger:  logger,
 		manager: manager,
+		rooms:   rooms,
 	}
 }
 
@@ -26,3 +33,110 @@ func (c *GroupController) ListGroupIDs(w http.ResponseWriter, r *http.Request) {
 		return groupIDs, nil
 	})
 }
+
+func (c *GroupController) GetGroup(w http.ResponseWriter, r *http.Request) {
+	easyHandler("get group", w, r, c.logger, func(ctx context.Context) (manager.Group, error) {
+		groupID := r.PathValue("group_id")
+
+		group, err := c.manager.GetGroup(ctx, groupID)
+		if err != nil {
+			return manager.Group{}, groupAppError(groupID, err)
+		}
+
+		return group, nil
+	})
+}
+
+// ListGroupSeats returns the seats reserved by the group keyed by room id.
+func (c *GroupController) ListGroupSeats(w http.ResponseWriter, r *http.Request) {
+	easyHandler("list group seats", w, r, c.logger, func(ctx context.Context) (map[string][]manager.Reservation, error) {
+		groupID := r.PathValue("group_id")
+
+		if _, err := c.manager.GetGroup(ctx, groupID); err != nil {
+			return nil, groupAppError(groupID, err)
+		}
+
+		return c.rooms.ListGroupReservations(ctx, groupID), nil
+	})
+}
+
+func (c *GroupController) AddGroup(w http.ResponseWriter, r *http.Request) {
+	easyHandler("add group", w, r, c.logger, func(ctx context.Context) (manager.Group, error) {
+		req, err := decodeValid[request.GroupCreation](r)
+		if err != nil {
+			return manager.Group{}, err
+		}
+
+		group, err := c.manager.AddGroup(ctx, req.ID)
+		if err != nil {
+			return manager.Group{}, groupAppError(req.ID, err)
+		}
+
+		return group, nil
+	})
+}
+
+// DeleteGroup refuses to delete a group that still has seats unless the
+// cascade query parameter is true, in which case its seats are released.
+// A cascade that is not a boolean is rejected.
+func (c *GroupController) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	easyHandler("delete group", w, r, c.logger, func(ctx context.Context) (any, error) {
+		groupID := r.PathValue("group_id")
+		cascade := false
+		if v := r.URL.Query().Get("cascade"); v != "" {
+			var err error
+			if cascade, err = strconv.ParseBool(v); err != nil {
+				return nil, AppError{
+					ErrCode:    errcode.InvalidParameters,
+					HttpStatus: http.StatusUnprocessableEntity,
+					err:        ValidationErrors{"cascade": "cascade must be a boolean"},
+				}
+			}
+		}
+
+		count, err := c.rooms.DeleteGroup(ctx, groupID, cascade)
+		if errors.Is(err, manager.ErrGroupHasSeats) {
+			return nil, AppError{
+				ErrCode:    errcode.ResourceConflict,
+				HttpStatus: http.StatusConflict,
+				Message:    fmt.Sprintf("group %q still has %d seats", groupID, count),
+				err:        err,
+			}
+		}
+		if rErr := (manager.GroupReleaseError{}); errors.As(err, &rErr) {
+			return nil, AppError{
+				ErrCode:    errcode.InternalError,
+				HttpStatus: http.StatusInternalServerError,
+				Message: fmt.Sprintf("group %q was released in rooms %v but not in room %q and is kept, retry the delete",
+					groupID, rErr.Released, rErr.RoomID),
+				err: err,
+			}
+		}
+		if err != nil {
+			return nil, groupAppError(groupID, err)
+		}
+
+		return nil, nil
+	})
+}
+
+func groupAppError(groupID string, err error) error {
+	switch {
+	case errors.Is(err, manager.ErrGroupIdNotFound):
+		return AppError{
+			ErrCode:    errcode.ResourceNotFound,
+			HttpStatus: http.StatusNotFound,
+			Message:    fmt.Sprintf("group %q not found", groupID),
+			err:        err,
+		}
+	case errors.Is(err, manager.ErrGroupIDExists):
+		return AppError{
+			ErrCode:    errcode.ResourceConflict,
+			HttpStatus: http.StatusConflict,
+			Message:    fmt.Sprintf("group %q already exists", groupID),
+			err:        err,
+		}
+	}
+
+	return err
+}
@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/namlh/vulcanLabsOA/config"
 	"github.com/namlh/vulcanLabsOA/controller"
 	"github.com/namlh/vulcanLabsOA/manager"
 	"github.com/namlh/vulcanLabsOA/testing/assert"
@@ -20,7 +21,7 @@ func TestGroupController_ListGroupIDs(t *testing.T) {
 	t.Cleanup(cancel)
 
 	groupManager := manager.NewGroupManager([]string{"abc", "xyz", "123"})
-	ctrl := controller.NewGroupController(slog.Default(), groupManager)
+	ctrl := controller.NewGroupController(slog.Default(), groupManager, nil)
 
 	srv := httptest.NewServer(http.HandlerFunc(ctrl.ListGroupIDs))
 	t.Cleanup(srv.Close)
@@ -37,3 +38,92 @@ func TestGroupController_ListGroupIDs(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, `{"code":0,"message":"Success","data":["abc","xyz","123"]}`, string(buf))
 }
+
+func TestGroupController_ManageGroups(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	srv := newTestServer(t, testRoom{groupIDs: []string{"abc"}})
+
+	status, body := doRequest(t, ctx, "POST", srv.URL+"/api/v1/groups", `{"id":"xyz"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"code":0,"message":"Success","data":{"id":"xyz"}}`, body)
+
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/groups", `{"id":"xyz"}`)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, `{"code":3,"message":"group \"xyz\" already exists"}`, body)
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/groups/xyz", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"code":0,"message":"Success","data":{"id":"xyz"}}`, body)
+
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/groups", `{"id":"def"}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"xyz","position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, body = doRequest(t, ctx, "DELETE", srv.URL+"/api/v1/groups/xyz", "")
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, `{"code":3,"message":"group \"xyz\" still has 1 seats"}`, body)
+
+	// a refused delete keeps the group in place
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/groups", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"code":0,"message":"Success","data":["abc","xyz","def"]}`, body)
+
+	status, body = doRequest(t, ctx, "DELETE", srv.URL+"/api/v1/groups/xyz?cascade=yes", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","details":{"cascade":"cascade must be a boolean"}}`, body)
+
+	status, _ = doRequest(t, ctx, "DELETE", srv.URL+"/api/v1/groups/xyz?cascade=true", "")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = doRequest(t, ctx, "GET", srv.URL+"/api/v1/groups/xyz", "")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/groups", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"code":0,"message":"Success","data":["abc","def"]}`, body)
+
+	// the seats of the deleted group are free again
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"abc","position":[0,1]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	// waitlist entries do not block the delete and are dropped with it
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/waitlist", `{"group_id":"def","positions":[[0,2]]}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = doRequest(t, ctx, "DELETE", srv.URL+"/api/v1/groups/def", "")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/waitlist", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 0, len(decodeData[[]manager.WaitlistEntry](t, body)))
+}
+
+func TestGroupController_ListGroupSeats(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	srv := newTestServer(t, testRoom{rooms: []config.Room{
+		{ID: "main", NumRows: 4, NumCols: 4, MinDistance: 3},
+		{ID: "studio", NumRows: 2, NumCols: 2, MinDistance: 1},
+	}})
+
+	room, err := srv.rooms.GetRoom(ctx, "studio")
+	assert.NoError(t, err)
+	booking, err := room.ReserveSeats(ctx, []manager.Seat{{GroupID: "abc", Coordinate: manager.Coordinate{1, 0}}})
+	assert.NoError(t, err)
+
+	status, body := doRequest(t, ctx, "GET", srv.URL+"/api/v1/groups/abc/seats", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"code":0,"message":"Success","data":{"main":[],"studio":[{"group_id":"abc","position":[1,0],"booking_id":"`+booking.ID+`"}]}}`, body)
+
+	status, _ = doRequest(t, ctx, "GET", srv.URL+"/api/v1/groups/unknown/seats", "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/namlh/vulcanLabsOA/consts/errcode"
+	"github.com/namlh/vulcanLabsOA/manager"
+)
+
+// HistoryController rebuilds past states of rooms from their event history.
+type HistoryController struct {
+	logger       *slog.Logger
+	rooms        manager.RoomRegistry
+	groupManager manager.GroupManager
+	history      manager.EventHistory
+}
+
+func NewHistoryController(
+	logger *slog.Logger,
+	rooms manager.RoomRegistry,
+	groupManager manager.GroupManager,
+	history manager.EventHistory,
+) *HistoryController {
+	return &HistoryController{
+		logger:       logger,
+		rooms:        rooms,
+		groupManager: groupManager,
+		history:      history,
+	}
+}
+
+type RoomStateRef struct {
+	Version uint64    `json:"version"`
+	Time    time.Time `json:"time"`
+}
+
+// RoomStateDiff lists what changed in a room between two points of its
+// history.
+type RoomStateDiff struct {
+	RoomID           string                          `json:"room_id"`
+	From             RoomStateRef                    `json:"from"`
+	To               RoomStateRef                    `json:"to"`
+	Reserved         []manager.Reservation           `json:"reserved"`
+	Cancelled        []manager.Reservation           `json:"cancelled"`
+	AvailableAdded   map[string][]manager.Coordinate `json:"available_added"`
+	AvailableRemoved map[string][]manager.Coordinate `json:"available_removed"`
+}
+
+// GetRoomState returns the room as of the time given by at, or the version
+// given by version. A point older than the history kept is answered with
+// 410 and the earliest point that can be rebuilt.
+func (c *HistoryController) GetRoomState(w http.ResponseWriter, r *http.Request) {
+	easyHandler("get room state", w, r, c.logger, func(ctx context.Context) (manager.RoomState, error) {
+		query := r.URL.Query()
+
+		problems := make(ValidationErrors)
+		point := parseHistoryPoint(query, "at", "version", problems)
+
+		return c.replay(ctx, r.PathValue("room_id"), point, problems)
+	})
+}
+
+// DiffRoomState compares the room between two points, each given like the
+// point of GetRoomState: from or from_version, and to or to_version.
+func (c *HistoryController) DiffRoomState(w http.ResponseWriter, r *http.Request) {
+	easyHandler("diff room state", w, r, c.logger, func(ctx context.Context) (RoomStateDiff, error) {
+		query := r.URL.Query()
+
+		problems := make(ValidationErrors)
+		from := parseHistoryPoint(query, "from", "from_version", problems)
+		to := parseHistoryPoint(query, "to", "to_version", problems)
+
+		fromState, err := c.replay(ctx, r.PathValue("room_id"), from, problems)
+		if err != nil {
+			return RoomStateDiff{}, err
+		}
+		toState, err := c.replay(ctx, r.PathValue("room_id"), to, problems)
+		if err != nil {
+			return RoomStateDiff{}, err
+		}
+
+		return RoomStateDiff{
+			RoomID:           fromState.RoomID,
+			From:             RoomStateRef{Version: fromState.Version, Time: fromState.Time},
+			To:               RoomStateRef{Version: toState.Version, Time: toState.Time},
+			Reserved:         diffReservations(toState.Reserved, fromState.Reserved),
+			Cancelled:        diffReservations(fromState.Reserved, toState.Reserved),
+			AvailableAdded:   diffSeats(toState.Available, fromState.Available),
+			AvailableRemoved: diffSeats(fromState.Available, toState.Available),
+		}, nil
+	})
+}
+
+// replay rebuilds the room at point. It fails with problems when there
+// are any.
+func (c *HistoryController) replay(
+	ctx context.Context,
+	roomID string,
+	point manager.HistoryPoint,
+	problems ValidationErrors,
+) (manager.RoomState, error) {
+	if len(problems) > 0 {
+		return manager.RoomState{}, AppError{
+			ErrCode:    errcode.InvalidParameters,
+			HttpStatus: http.StatusUnprocessableEntity,
+			err:        problems,
+		}
+	}
+
+	room, err := c.rooms.GetRoom(ctx, roomID)
+	if err != nil {
+		return manager.RoomState{}, roomAppError(err, roomID)
+	}
+
+	events, err := c.history.Events(ctx, roomID)
+	if err != nil {
+		return manager.RoomState{}, fmt.Errorf("list events: %w", err)
+	}
+
+	state, err := manager.ReplayRoomState(ctx, c.logger, room.Config(ctx), c.groupManager, events, point)
+	if nErr := (manager.HistoryNotRetainedError{}); errors.As(err, &nErr) {
+		message := fmt.Sprintf("history of room %q not retained", roomID)
+		if nErr.Earliest != nil {
+			message = fmt.Sprintf("history of room %q not retained before version %d at %s",
+				roomID, nErr.Earliest.Version, nErr.Earliest.Time.Format(time.RFC3339Nano))
+		}
+		return manager.RoomState{}, AppError{
+			ErrCode:    errcode.InvalidParameters,
+			HttpStatus: http.StatusGone,
+			Message:    message,
+			err:        err,
+		}
+	}
+	if err != nil {
+		return manager.RoomState{}, fmt.Errorf("replay room state: %w", err)
+	}
+
+	return state, nil
+}
+
+// parseHistoryPoint reads a point of the history given either as an RFC
+// 3339 time by timeKey or as a room version by versionKey.
+func parseHistoryPoint(query url.Values, timeKey, versionKey string, problems ValidationErrors) manager.HistoryPoint {
+	var point manager.HistoryPoint
+	switch at, version := query.Get(timeKey), query.Get(versionKey); {
+	case at != "" && version != "":
+		problems[timeKey] = fmt.Sprintf("only one of %s and %s must be set", timeKey, versionKey)
+	case at != "":
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			problems[timeKey] = timeKey + " must be an RFC 3339 time"
+		}
+		point.Time = t
+	case version != "":
+		v, err := strconv.ParseUint(version, 10, 64)
+		if err != nil {
+			problems[versionKey] = versionKey + " must be a room version"
+		}
+		point.Version = v
+	default:
+		problems[timeKey] = fmt.Sprintf("one of %s and %s must be set", timeKey, versionKey)
+	}
+
+	return point
+}
+
+// diffReservations returns the reservations of a that are not in b.
+func diffReservations(a, b []manager.Reservation) []manager.Reservation {
+	inB := make(map[manager.Reservation]struct{}, len(b))
+	for _, reservation := range b {
+		inB[reservation] = struct{}{}
+	}
+
+	diff := make([]manager.Reservation, 0)
+	for _, reservation := range a {
+		if _, ok := inB[reservation]; !ok {
+			diff = append(diff, reservation)
+		}
+	}
+
+	return diff
+}
@@ -0,0 +1,125 @@
+package controller_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/namlh/vulcanLabsOA/controller"
+	"github.com/namlh/vulcanLabsOA/manager"
+	"github.com/namlh/vulcanLabsOA/testing/assert"
+)
+
+func TestHistoryController(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	history := manager.NewMemoryEventHistory(slog.Default(), 0)
+	srv := newTestServer(t, testRoom{history: history})
+	assert.NoError(t, srv.rooms.Restore(ctx))
+
+	status, _ := doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"abc","position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+	afterReserve := time.Now()
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"xyz","position":[3,3]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/cancellation", `{"seats_cancellation":[{"position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	events, err := history.Events(ctx, "main")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(events))
+	assert.Equal(t, manager.EventRestore, events[0].Type)
+	first := strconv.FormatUint(events[1].Version, 10)
+	last := strconv.FormatUint(events[3].Version, 10)
+
+	status, body := doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/state?at="+url.QueryEscape(afterReserve.Format(time.RFC3339Nano)), "")
+	assert.Equal(t, http.StatusOK, status)
+	state := decodeData[manager.RoomState](t, body)
+	assert.Equal(t, events[1].Version, state.Version)
+	assert.Equal(t, 1, len(state.Reserved))
+	assert.Equal(t, manager.Seat{GroupID: "abc", Coordinate: manager.Coordinate{0, 0}}, state.Reserved[0].Seat)
+	// [0,0] blocks the seats of xyz closer than the min distance
+	assert.Equal(t, 15, len(state.Available["abc"]))
+	assert.Equal(t, 10, len(state.Available["xyz"]))
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/state?version="+last, "")
+	assert.Equal(t, http.StatusOK, status)
+	state = decodeData[manager.RoomState](t, body)
+	assert.Equal(t, 1, len(state.Reserved))
+	assert.Equal(t, manager.Seat{GroupID: "xyz", Coordinate: manager.Coordinate{3, 3}}, state.Reserved[0].Seat)
+
+	// the room cannot be rebuilt before its first restore
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/state?at=2000-01-01T00:00:00Z", "")
+	assert.Equal(t, http.StatusGone, status)
+	assert.Equal(t, `{"code":1,"message":"history of room \"main\" not retained before version `+
+		strconv.FormatUint(events[0].Version, 10)+` at `+events[0].Time.Format(time.RFC3339Nano)+`"}`, body)
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/state?version="+strconv.FormatUint(events[0].Version, 10), "")
+	assert.Equal(t, http.StatusOK, status)
+	state = decodeData[manager.RoomState](t, body)
+	assert.Equal(t, events[0].Version, state.Version)
+	assert.Equal(t, 0, len(state.Reserved))
+	assert.Equal(t, 16, len(state.Available["xyz"]))
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/state/diff?from_version="+first+"&to_version="+last, "")
+	assert.Equal(t, http.StatusOK, status)
+	diff := decodeData[controller.RoomStateDiff](t, body)
+	assert.Equal(t, events[1].Version, diff.From.Version)
+	assert.Equal(t, events[3].Version, diff.To.Version)
+	assert.Equal(t, 1, len(diff.Reserved))
+	assert.Equal(t, manager.Coordinate{3, 3}, diff.Reserved[0].Coordinate)
+	assert.Equal(t, 1, len(diff.Cancelled))
+	assert.Equal(t, manager.Seat{GroupID: "abc", Coordinate: manager.Coordinate{0, 0}}, diff.Cancelled[0].Seat)
+	assert.Equal(t, 6, len(diff.AvailableAdded["xyz"]))
+	assert.Equal(t, manager.Coordinate{3, 3}, diff.AvailableRemoved["xyz"][0])
+
+	// a time and a version can be mixed
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/state/diff?from="+url.QueryEscape(events[0].Time.Format(time.RFC3339Nano))+"&to_version="+last, "")
+	assert.Equal(t, http.StatusOK, status)
+	diff = decodeData[controller.RoomStateDiff](t, body)
+	assert.Equal(t, events[0].Version, diff.From.Version)
+	assert.Equal(t, events[3].Version, diff.To.Version)
+	assert.Equal(t, 1, len(diff.Reserved))
+	assert.Equal(t, 0, len(diff.Cancelled))
+
+	// the replay follows reconfigurations
+	room, err := srv.rooms.GetRoom(ctx, "main")
+	assert.NoError(t, err)
+	wider := room.Config(ctx)
+	wider.NumCols = 5
+	_, err = room.Reconfigure(ctx, wider, false)
+	assert.NoError(t, err)
+	events, err = history.Events(ctx, "main")
+	assert.NoError(t, err)
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/state?version="+strconv.FormatUint(events[len(events)-1].Version, 10), "")
+	assert.Equal(t, http.StatusOK, status)
+	state = decodeData[manager.RoomState](t, body)
+	assert.Equal(t, 5, state.Room.NumCols)
+	assert.Equal(t, 19, len(state.Available["xyz"]))
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/state?version="+last, "")
+	assert.Equal(t, http.StatusOK, status)
+	state = decodeData[manager.RoomState](t, body)
+	assert.Equal(t, 4, state.Room.NumCols)
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/state", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","details":{"at":"one of at and version must be set"}}`, body)
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/state/diff?from="+first+"&to=2000-01-01T00:00:00Z&to_version="+last, "")
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","details":{"from":"from must be an RFC 3339 time","to":"only one of to and to_version must be set"}}`, body)
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/state/diff?from_version=yesterday", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","details":{"from_version":"from_version must be a room version","to":"one of to and to_version must be set"}}`, body)
+
+	status, _ = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/unknown/state?version="+last, "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
@@ -5,31 +5,109 @@ import (
 	"fmt"
 )
 
+// Codes of ItemProblem.
+const (
+	ItemCodeRequired     = "required"
+	ItemCodeOutOfBound   = "out_of_bound"
+	ItemCodeInvalidValue = "invalid_value"
+)
+
+// ItemProblem is a problem with one item of a list in a request. Requests
+// with a list report every problem of every item at once.
+type ItemProblem struct {
+	Index   int
+	Field   string
+	Code    string
+	Message string
+}
+
+func missing(i int, field string) ItemProblem {
+	return ItemProblem{
+		Index:   i,
+		Field:   field,
+		Code:    ItemCodeRequired,
+		Message: fmt.Sprintf("%s at index %d must not be empty", field, i),
+	}
+}
+
+func negative(i int, field string) ItemProblem {
+	return ItemProblem{
+		Index:   i,
+		Field:   field,
+		Code:    ItemCodeOutOfBound,
+		Message: fmt.Sprintf("%s at index %d must not be negative", field, i),
+	}
+}
+
+func validPosition(problems []ItemProblem, i int, field string, position *[2]int) []ItemProblem {
+	if position == nil {
+		return append(problems, missing(i, field))
+	}
+	if position[0] < 0 || position[1] < 0 {
+		return append(problems, negative(i, field))
+	}
+
+	return problems
+}
+
+type GroupSeat struct {
+	GroupID  string  `json:"group_id"`
+	Position *[2]int `json:"position"`
+}
+
+const (
+	ReservationModeAtomic     = "atomic"
+	ReservationModeBestEffort = "best_effort"
+)
+
 type SeatsReservation struct {
-	SeatsReservation []struct {
-		GroupID  string  `json:"group_id"`
-		Position *[2]int `json:"position"`
-	} `json:"seats_reservation"`
+	SeatsReservation []GroupSeat `json:"seats_reservation"`
+	// Mode is either atomic, the default, or best_effort.
+	Mode string `json:"mode"`
 }
 
 func (s SeatsReservation) Valid(_ context.Context) map[string]string {
 	problems := make(map[string]string)
-	for i, data := range s.SeatsReservation {
-		if len(problems) > 0 {
-			break
-		}
+	if len(s.SeatsReservation) == 0 {
+		problems["seats_reservation"] = "seats_reservation must not be empty"
+	}
+	switch s.Mode {
+	case "", ReservationModeAtomic, ReservationModeBestEffort:
+	default:
+		problems["mode"] = fmt.Sprintf("mode must be %s or %s", ReservationModeAtomic, ReservationModeBestEffort)
+	}
 
-		if data.Position == nil {
-			problems["position"] = fmt.Sprintf("position at index %d must not be empty", i)
-		} else {
-			if (*data.Position)[0] < 0 || (*data.Position)[1] < 0 {
-				problems["position"] = fmt.Sprintf("position at index %d must be greater than 0", i)
-			}
-			if data.GroupID == "" {
-				problems["group_id"] = fmt.Sprintf("group_id at index %d must not be empty", i)
-			}
-		}
+	return problems
+}
 
+func (s SeatsReservation) ValidItems(_ context.Context) []ItemProblem {
+	return validGroupSeats(s.SeatsReservation)
+}
+
+type SeatsHold struct {
+	SeatsHold []GroupSeat `json:"seats_hold"`
+}
+
+func (s SeatsHold) Valid(_ context.Context) map[string]string {
+	problems := make(map[string]string)
+	if len(s.SeatsHold) == 0 {
+		problems["seats_hold"] = "seats_hold must not be empty"
+	}
+
+	return problems
+}
+
+func (s SeatsHold) ValidItems(_ context.Context) []ItemProblem {
+	return validGroupSeats(s.SeatsHold)
+}
+
+func validGroupSeats(seats []GroupSeat) []ItemProblem {
+	var problems []ItemProblem
+	for i, data := range seats {
+		if data.GroupID == "" {
+			problems = append(problems, missing(i, "group_id"))
+		}
+		problems = validPosition(problems, i, "position", data.Position)
 	}
 
 	return problems
@@ -43,14 +121,180 @@ type SeatsCancellation struct {
 
 func (s SeatsCancellation) Valid(_ context.Context) map[string]string {
 	problems := make(map[string]string)
+	if len(s.SeatsCancellation) == 0 {
+		problems["seats_cancellation"] = "seats_cancellation must not be empty"
+	}
+
+	return problems
+}
+
+func (s SeatsCancellation) ValidItems(_ context.Context) []ItemProblem {
+	var problems []ItemProblem
 	for i, data := range s.SeatsCancellation {
-		if len(problems) > 0 {
-			break
-		}
+		problems = validPosition(problems, i, "position", &data.Position)
+	}
+
+	return problems
+}
+
+type SeatsMove struct {
+	SeatsMove []struct {
+		From *[2]int `json:"from"`
+		To   *[2]int `json:"to"`
+	} `json:"seats_move"`
+}
+
+func (s SeatsMove) Valid(_ context.Context) map[string]string {
+	problems := make(map[string]string)
+	if len(s.SeatsMove) == 0 {
+		problems["seats_move"] = "seats_move must not be empty"
+	}
+
+	return problems
+}
+
+func (s SeatsMove) ValidItems(_ context.Context) []ItemProblem {
+	var problems []ItemProblem
+	for i, data := range s.SeatsMove {
+		problems = validPosition(problems, i, "from", data.From)
+		problems = validPosition(problems, i, "to", data.To)
+	}
+
+	return problems
+}
+
+type SeatsTransaction struct {
+	Operations []struct {
+		Type     string  `json:"type"`
+		GroupID  string  `json:"group_id"`
+		Position *[2]int `json:"position"`
+	} `json:"operations"`
+}
+
+func (s SeatsTransaction) Valid(_ context.Context) map[string]string {
+	problems := make(map[string]string)
+	if len(s.Operations) == 0 {
+		problems["operations"] = "operations must not be empty"
+	}
+
+	return problems
+}
 
-		if data.Position[0] < 0 || data.Position[1] < 0 {
-			problems["position"] = fmt.Sprintf("position at index %d must be greater than 0", i)
+func (s SeatsTransaction) ValidItems(_ context.Context) []ItemProblem {
+	var problems []ItemProblem
+	for i, op := range s.Operations {
+		switch op.Type {
+		case "reserve":
+			if op.GroupID == "" {
+				problems = append(problems, missing(i, "group_id"))
+			}
+		case "cancel":
+		default:
+			problems = append(problems, ItemProblem{
+				Index:   i,
+				Field:   "type",
+				Code:    ItemCodeInvalidValue,
+				Message: fmt.Sprintf("type at index %d must be reserve or cancel", i),
+			})
 		}
+		problems = validPosition(problems, i, "position", op.Position)
+	}
+
+	return problems
+}
+
+type SeatsAllocation struct {
+	GroupID string `json:"group_id"`
+	Count   int    `json:"count"`
+}
+
+func (s SeatsAllocation) Valid(_ context.Context) map[string]string {
+	problems := make(map[string]string)
+	if s.GroupID == "" {
+		problems["group_id"] = "group_id must not be empty"
+	}
+	if s.Count <= 0 {
+		problems["count"] = "count must be greater than 0"
+	}
+
+	return problems
+}
+
+// WaitlistJoin waits for either Count seats anywhere in the room or for the
+// seats at Positions.
+type WaitlistJoin struct {
+	GroupID   string   `json:"group_id"`
+	Count     int      `json:"count"`
+	Positions [][2]int `json:"positions"`
+}
+
+func (w WaitlistJoin) Valid(_ context.Context) map[string]string {
+	problems := make(map[string]string)
+	if w.GroupID == "" {
+		problems["group_id"] = "group_id must not be empty"
+	}
+
+	switch {
+	case w.Count < 0:
+		problems["count"] = "count must not be negative"
+	case w.Count > 0 && len(w.Positions) > 0:
+		problems["count"] = "only one of count and positions must be set"
+	case w.Count == 0 && len(w.Positions) == 0:
+		problems["count"] = "one of count and positions must be set"
+	}
+
+	return problems
+}
+
+func (w WaitlistJoin) ValidItems(_ context.Context) []ItemProblem {
+	var problems []ItemProblem
+	for i, position := range w.Positions {
+		problems = validPosition(problems, i, "positions", &position)
+	}
+
+	return problems
+}
+
+// RoomReconfiguration changes the settings of a room that are set and
+// keeps the others.
+type RoomReconfiguration struct {
+	NumRows     *int `json:"num_rows"`
+	NumCols     *int `json:"num_cols"`
+	MinDistance *int `json:"min_distance"`
+	// Layout replaces the seat map, and sets the dimensions that are not
+	// set. An empty layout makes every cell a seat.
+	Layout []string `json:"layout"`
+	// Force applies the change even when reserved or held seats conflict
+	// with it.
+	Force bool `json:"force"`
+}
+
+func (c RoomReconfiguration) Valid(_ context.Context) map[string]string {
+	problems := make(map[string]string)
+	if c.NumRows == nil && c.NumCols == nil && c.MinDistance == nil && c.Layout == nil {
+		problems["room"] = "one of num_rows, num_cols, min_distance and layout must be set"
+	}
+	if c.NumRows != nil && *c.NumRows <= 0 {
+		problems["num_rows"] = "num_rows must be greater than 0"
+	}
+	if c.NumCols != nil && *c.NumCols <= 0 {
+		problems["num_cols"] = "num_cols must be greater than 0"
+	}
+	if c.MinDistance != nil && *c.MinDistance < 0 {
+		problems["min_distance"] = "min_distance must not be negative"
+	}
+
+	return problems
+}
+
+type GroupCreation struct {
+	ID string `json:"id"`
+}
+
+func (g GroupCreation) Valid(_ context.Context) map[string]string {
+	problems := make(map[string]string)
+	if g.ID == "" {
+		problems["id"] = "id must not be empty"
 	}
 
 	return problems
@@ -6,30 +6,77 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 
+	"github.com/namlh/vulcanLabsOA/config"
 	"github.com/namlh/vulcanLabsOA/consts/errcode"
 	"github.com/namlh/vulcanLabsOA/controller/request"
 	"github.com/namlh/vulcanLabsOA/manager"
 )
 
 type RoomController struct {
-	logger  *slog.Logger
-	manager manager.RoomManager
+	logger       *slog.Logger
+	rooms        manager.RoomRegistry
+	groupManager manager.GroupManager
 }
 
-func NewRoomController(logger *slog.Logger, manager manager.RoomManager) *RoomController {
+func NewRoomController(logger *slog.Logger, rooms manager.RoomRegistry, groupManager manager.GroupManager) *RoomController {
 	return &RoomController{
-		logger:  logger,
-		manager: manager,
+		logger:       logger,
+		rooms:        rooms,
+		groupManager: groupManager,
 	}
 }
 
+func (c *RoomController) room(r *http.Request) (manager.RoomManager, error) {
+	roomID := r.PathValue("room_id")
+
+	room, err := c.rooms.GetRoom(r.Context(), roomID)
+	if err != nil {
+		return nil, roomAppError(err, roomID)
+	}
+
+	return room, nil
+}
+
+func roomAppError(err error, roomID string) error {
+	if errors.Is(err, manager.ErrRoomNotFound) {
+		return AppError{
+			ErrCode:    errcode.ResourceNotFound,
+			HttpStatus: http.StatusNotFound,
+			Message:    fmt.Sprintf("room %q not found", roomID),
+			err:        err,
+		}
+	}
+
+	return fmt.Errorf("get room: %w", err)
+}
+
+func (c *RoomController) ListRooms(w http.ResponseWriter, r *http.Request) {
+	easyHandler("list rooms", w, r, c.logger, func(ctx context.Context) ([]config.Room, error) {
+		return c.rooms.ListRooms(ctx), nil
+	})
+}
+
 func (c *RoomController) ListAvailableSeats(w http.ResponseWriter, r *http.Request) {
 	easyHandler("list available seats", w, r, c.logger, func(ctx context.Context) (map[string][]manager.Coordinate, error) {
+		room, err := c.room(r)
+		if err != nil {
+			return nil, err
+		}
+
+		// the generation is read first, so that a group change racing with
+		// the listing yields a stale tag rather than a stale body
+		generation := c.groupManager.Generation(ctx)
+		if etag := formatETag(room.Version(ctx), generation); etagMatch(r.Header.Get("If-None-Match"), etag, true) {
+			w.Header().Set("ETag", etag)
+			return nil, errNotModified
+		}
+
 		query := r.URL.Query()
 		groupID := query.Get("group_id")
 
-		seats, err := c.manager.ListAvailableSeats(ctx, groupID)
+		seats, version, err := room.ListAvailableSeats(ctx, groupID)
 		if err != nil {
 			if errors.Is(err, manager.ErrGroupIdNotFound) {
 				return nil, AppError{
@@ -43,59 +90,135 @@ func (c *RoomController) ListAvailableSeats(w http.ResponseWriter, r *http.Reque
 
 			return nil, fmt.Errorf("list available seats: %w", err)
 		}
+		w.Header().Set("ETag", formatETag(version, generation))
 
 		return seats, nil
 	})
 }
 
+func (c *RoomController) ListReservations(w http.ResponseWriter, r *http.Request) {
+	easyHandler("list reservations", w, r, c.logger, func(ctx context.Context) ([]manager.Reservation, error) {
+		room, err := c.room(r)
+		if err != nil {
+			return nil, err
+		}
+
+		groupID := r.URL.Query().Get("group_id")
+
+		return room.ListReservations(ctx, groupID), nil
+	})
+}
+
 func (c *RoomController) ReserveSeats(w http.ResponseWriter, r *http.Request) {
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+		c.ValidateReservation(w, r)
+		return
+	}
+
 	easyHandler("reserve seats", w, r, c.logger, func(ctx context.Context) (any, error) {
+		room, err := c.room(r)
+		if err != nil {
+			return nil, err
+		}
+
 		req, err := decodeValid[request.SeatsReservation](r)
 		if err != nil {
 			return nil, err
 		}
 
-		seats := make([]manager.Seat, len(req.SeatsReservation))
-		for i := range req.SeatsReservation {
-			seats[i] = manager.Seat{
-				GroupID:    req.SeatsReservation[i].GroupID,
-				Coordinate: manager.Coordinate(*req.SeatsReservation[i].Position),
+		ctx, setETag := c.conditional(ctx, w, r)
+
+		seats := toSeats(req.SeatsReservation)
+		if req.Mode == request.ReservationModeBestEffort {
+			booking, sErrs, err := room.ReserveSeatsBestEffort(ctx, seats)
+			if err != nil {
+				return nil, seatAppError(err)
 			}
+
+			setETag()
+			return newBestEffortReservation(booking, seats, sErrs), nil
 		}
 
-		if err := c.manager.ReserveSeats(ctx, seats); err != nil {
-			if sErr := (manager.SeatError{}); errors.As(err, &sErr) {
-				appErr := AppError{
-					ErrCode:    errcode.InvalidParameters,
-					HttpStatus: http.StatusUnprocessableEntity,
-				}
+		booking, err := room.ReserveSeats(ctx, seats)
+		if err != nil {
+			return nil, seatAppError(err)
+		}
+		setETag()
 
-				field := ""
-				switch sErr.Code {
-				case manager.SeatErrorCodeOutOfBound, manager.SeatErrorCodeSeatTaken,
-					manager.SeatErrorCodeInvalidDistance, manager.SeatErrorCodeDuplicatedPosition:
-					field = "position"
-				case manager.SeatErrorCodeGroupIDNotFound:
-					field = "group_id"
-				default:
-					panic("unhandled default case")
-				}
+		return booking, nil
+	})
+}
 
-				appErr.err = ValidationErrors{
-					field: sErr.Error(),
-				}
+type SeatResult struct {
+	Index    int                `json:"index"`
+	GroupID  string             `json:"group_id"`
+	Position manager.Coordinate `json:"position"`
+	Accepted bool               `json:"accepted"`
+	Errors   FieldErrors        `json:"errors,omitempty"`
+}
 
-				return nil, appErr
-			}
-			return nil, err
+type BestEffortReservation struct {
+	BookingID string       `json:"booking_id,omitempty"`
+	Results   []SeatResult `json:"results"`
+}
+
+func newBestEffortReservation(booking manager.Booking, seats []manager.Seat, sErrs manager.SeatErrors) BestEffortReservation {
+	results := make([]SeatResult, len(seats))
+	for i, seat := range seats {
+		results[i] = SeatResult{
+			Index:    i,
+			GroupID:  seat.GroupID,
+			Position: seat.Coordinate,
+			Accepted: true,
 		}
+	}
+	for _, fErr := range toFieldErrors(sErrs) {
+		results[fErr.Index].Accepted = false
+		results[fErr.Index].Errors = append(results[fErr.Index].Errors, fErr)
+	}
 
-		return nil, nil
+	return BestEffortReservation{
+		BookingID: booking.ID,
+		Results:   results,
+	}
+}
+
+type ReservationVerdict struct {
+	Valid  bool        `json:"valid"`
+	Errors FieldErrors `json:"errors,omitempty"`
+}
+
+func (c *RoomController) ValidateReservation(w http.ResponseWriter, r *http.Request) {
+	easyHandler("validate reservation", w, r, c.logger, func(ctx context.Context) (ReservationVerdict, error) {
+		room, err := c.room(r)
+		if err != nil {
+			return ReservationVerdict{}, err
+		}
+
+		req, err := decodeValid[request.SeatsReservation](r)
+		if err != nil {
+			return ReservationVerdict{}, err
+		}
+
+		err = room.ValidateSeats(ctx, toSeats(req.SeatsReservation))
+		if sErrs := (manager.SeatErrors{}); errors.As(err, &sErrs) {
+			return ReservationVerdict{Errors: toFieldErrors(sErrs)}, nil
+		}
+		if err != nil {
+			return ReservationVerdict{}, fmt.Errorf("validate seats: %w", err)
+		}
+
+		return ReservationVerdict{Valid: true}, nil
 	})
 }
 
 func (c *RoomController) CancelSeats(w http.ResponseWriter, r *http.Request) {
 	easyHandler("cancel seats", w, r, c.logger, func(ctx context.Context) (any, error) {
+		room, err := c.room(r)
+		if err != nil {
+			return nil, err
+		}
+
 		req, err := decodeValid[request.SeatsCancellation](r)
 		if err != nil {
 			return nil, err
@@ -105,10 +228,515 @@ func (c *RoomController) CancelSeats(w http.ResponseWriter, r *http.Request) {
 			seats[i] = req.SeatsCancellation[i].Position
 		}
 
-		if err := c.manager.CancelSeats(ctx, seats); err != nil {
+		ctx, setETag := c.conditional(ctx, w, r)
+
+		if err := room.CancelSeats(ctx, seats); err != nil {
+			return nil, seatAppError(err)
+		}
+		setETag()
+
+		return nil, nil
+	})
+}
+
+func (c *RoomController) MoveSeats(w http.ResponseWriter, r *http.Request) {
+	easyHandler("move seats", w, r, c.logger, func(ctx context.Context) ([]manager.Reservation, error) {
+		room, err := c.room(r)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := decodeValid[request.SeatsMove](r)
+		if err != nil {
+			return nil, err
+		}
+		moves := make([]manager.SeatMove, len(req.SeatsMove))
+		for i, move := range req.SeatsMove {
+			moves[i] = manager.SeatMove{From: *move.From, To: *move.To}
+		}
+
+		ctx, setETag := c.conditional(ctx, w, r)
+
+		moved, err := room.MoveSeats(ctx, moves)
+		if err != nil {
+			return nil, moveAppError(err, moves)
+		}
+		setETag()
+
+		return moved, nil
+	})
+}
+
+func (c *RoomController) ApplyTransaction(w http.ResponseWriter, r *http.Request) {
+	easyHandler("apply transaction", w, r, c.logger, func(ctx context.Context) (manager.Mutation, error) {
+		room, err := c.room(r)
+		if err != nil {
+			return manager.Mutation{}, err
+		}
+
+		req, err := decodeValid[request.SeatsTransaction](r)
+		if err != nil {
+			return manager.Mutation{}, err
+		}
+		ops := make([]manager.Operation, len(req.Operations))
+		for i, op := range req.Operations {
+			ops[i] = manager.Operation{
+				Type: op.Type,
+				Seat: manager.Seat{GroupID: op.GroupID, Coordinate: *op.Position},
+			}
+		}
+
+		ctx, setETag := c.conditional(ctx, w, r)
+
+		mutation, err := room.ApplyTransaction(ctx, ops)
+		if err != nil {
+			return manager.Mutation{}, seatAppError(err)
+		}
+		setETag()
+
+		return mutation, nil
+	})
+}
+
+func (c *RoomController) HoldSeats(w http.ResponseWriter, r *http.Request) {
+	easyHandler("hold seats", w, r, c.logger, func(ctx context.Context) (manager.Hold, error) {
+		room, err := c.room(r)
+		if err != nil {
+			return manager.Hold{}, err
+		}
+
+		req, err := decodeValid[request.SeatsHold](r)
+		if err != nil {
+			return manager.Hold{}, err
+		}
+
+		ctx, setETag := c.conditional(ctx, w, r)
+
+		hold, err := room.HoldSeats(ctx, toSeats(req.SeatsHold))
+		if err != nil {
+			return manager.Hold{}, seatAppError(err)
+		}
+		setETag()
+
+		return hold, nil
+	})
+}
+
+func (c *RoomController) ConfirmHold(w http.ResponseWriter, r *http.Request) {
+	easyHandler("confirm hold", w, r, c.logger, func(ctx context.Context) (manager.Booking, error) {
+		room, err := c.room(r)
+		if err != nil {
+			return manager.Booking{}, err
+		}
+
+		ctx, setETag := c.conditional(ctx, w, r)
+
+		holdID := r.PathValue("hold_id")
+		booking, err := room.ConfirmHold(ctx, holdID)
+		if err != nil {
+			switch {
+			case errors.Is(err, manager.ErrVersionMismatch):
+				return manager.Booking{}, seatAppError(err)
+			case errors.Is(err, manager.ErrHoldNotFound):
+				return manager.Booking{}, AppError{
+					ErrCode:    errcode.ResourceNotFound,
+					HttpStatus: http.StatusNotFound,
+					Message:    fmt.Sprintf("hold %q not found", holdID),
+					err:        err,
+				}
+			case errors.Is(err, manager.ErrHoldExpired):
+				return manager.Booking{}, AppError{
+					ErrCode:    errcode.InvalidParameters,
+					HttpStatus: http.StatusGone,
+					Message:    fmt.Sprintf("hold %q expired", holdID),
+					err:        err,
+				}
+			}
+
+			return manager.Booking{}, fmt.Errorf("confirm hold: %w", err)
+		}
+		setETag()
+
+		return booking, nil
+	})
+}
+
+func (c *RoomController) GetBooking(w http.ResponseWriter, r *http.Request) {
+	easyHandler("get booking", w, r, c.logger, func(ctx context.Context) (manager.Booking, error) {
+		room, err := c.room(r)
+		if err != nil {
+			return manager.Booking{}, err
+		}
+
+		bookingID := r.PathValue("booking_id")
+		booking, err := room.GetBooking(ctx, bookingID)
+		if err != nil {
+			return manager.Booking{}, bookingAppError(bookingID, err)
+		}
+
+		return booking, nil
+	})
+}
+
+// CancelBooking cancels the listed seats of a booking, or all of them when
+// the request has no body.
+func (c *RoomController) CancelBooking(w http.ResponseWriter, r *http.Request) {
+	easyHandler("cancel booking", w, r, c.logger, func(ctx context.Context) (any, error) {
+		room, err := c.room(r)
+		if err != nil {
+			return nil, err
+		}
+
+		req := request.SeatsCancellation{}
+		if r.ContentLength != 0 {
+			req, err = decodeValid[request.SeatsCancellation](r)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		seats := make([]manager.Coordinate, len(req.SeatsCancellation))
+		for i := range req.SeatsCancellation {
+			seats[i] = req.SeatsCancellation[i].Position
+		}
+
+		ctx, setETag := c.conditional(ctx, w, r)
+
+		bookingID := r.PathValue("booking_id")
+		if err := room.CancelBooking(ctx, bookingID, seats); err != nil {
+			return nil, bookingAppError(bookingID, seatAppError(err))
+		}
+		setETag()
+
+		return nil, nil
+	})
+}
+
+func (c *RoomController) AllocateSeats(w http.ResponseWriter, r *http.Request) {
+	easyHandler("allocate seats", w, r, c.logger, func(ctx context.Context) (manager.Booking, error) {
+		room, err := c.room(r)
+		if err != nil {
+			return manager.Booking{}, err
+		}
+
+		req, err := decodeValid[request.SeatsAllocation](r)
+		if err != nil {
+			return manager.Booking{}, err
+		}
+
+		ctx, setETag := c.conditional(ctx, w, r)
+
+		booking, err := room.AllocateSeats(ctx, req.GroupID, req.Count)
+		if err != nil {
+			switch {
+			case errors.Is(err, manager.ErrVersionMismatch):
+				return manager.Booking{}, seatAppError(err)
+			case errors.Is(err, manager.ErrGroupIdNotFound):
+				return manager.Booking{}, AppError{
+					ErrCode:    errcode.InvalidParameters,
+					HttpStatus: http.StatusUnprocessableEntity,
+					err: ValidationErrors{
+						"group_id": "group_id not found",
+					},
+				}
+			case errors.Is(err, manager.ErrNotEnoughSeats):
+				return manager.Booking{}, AppError{
+					ErrCode:    errcode.InvalidParameters,
+					HttpStatus: http.StatusConflict,
+					Message:    fmt.Sprintf("cannot allocate %d seats for group %q", req.Count, req.GroupID),
+					err:        err,
+				}
+			}
+
+			return manager.Booking{}, fmt.Errorf("allocate seats: %w", err)
+		}
+		setETag()
+
+		return booking, nil
+	})
+}
+
+// JoinWaitlist queues a request for seats that is fulfilled as soon as
+// enough seats are freed. Freed seats go to the earliest entry they satisfy,
+// even when an earlier entry is still waiting for other seats. The returned
+// entry has a booking id when it is fulfilled right away.
+func (c *RoomController) JoinWaitlist(w http.ResponseWriter, r *http.Request) {
+	easyHandler("join waitlist", w, r, c.logger, func(ctx context.Context) (manager.WaitlistEntry, error) {
+		room, err := c.room(r)
+		if err != nil {
+			return manager.WaitlistEntry{}, err
+		}
+
+		req, err := decodeValid[request.WaitlistJoin](r)
+		if err != nil {
+			return manager.WaitlistEntry{}, err
+		}
+		seats := make([]manager.Coordinate, len(req.Positions))
+		for i, position := range req.Positions {
+			seats[i] = position
+		}
+
+		ctx, setETag := c.conditional(ctx, w, r)
+
+		entry, err := room.JoinWaitlist(ctx, req.GroupID, req.Count, seats)
+		if err != nil {
+			switch {
+			case errors.Is(err, manager.ErrGroupIdNotFound):
+				return manager.WaitlistEntry{}, AppError{
+					ErrCode:    errcode.InvalidParameters,
+					HttpStatus: http.StatusUnprocessableEntity,
+					err: ValidationErrors{
+						"group_id": "group_id not found",
+					},
+				}
+			case errors.Is(err, manager.ErrNotEnoughSeats):
+				return manager.WaitlistEntry{}, AppError{
+					ErrCode:    errcode.InvalidParameters,
+					HttpStatus: http.StatusUnprocessableEntity,
+					err: ValidationErrors{
+						"count": "count must not exceed the seats of the room",
+					},
+				}
+			}
+
+			return manager.WaitlistEntry{}, seatAppError(err)
+		}
+		setETag()
+
+		return entry, nil
+	})
+}
+
+func (c *RoomController) ListWaitlist(w http.ResponseWriter, r *http.Request) {
+	easyHandler("list waitlist", w, r, c.logger, func(ctx context.Context) ([]manager.WaitlistEntry, error) {
+		room, err := c.room(r)
+		if err != nil {
 			return nil, err
 		}
 
+		return room.ListWaitlist(ctx), nil
+	})
+}
+
+func (c *RoomController) LeaveWaitlist(w http.ResponseWriter, r *http.Request) {
+	easyHandler("leave waitlist", w, r, c.logger, func(ctx context.Context) (any, error) {
+		room, err := c.room(r)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, setETag := c.conditional(ctx, w, r)
+
+		entryID := r.PathValue("waitlist_id")
+		if err := room.LeaveWaitlist(ctx, entryID); err != nil {
+			if errors.Is(err, manager.ErrVersionMismatch) {
+				return nil, seatAppError(err)
+			}
+			if errors.Is(err, manager.ErrWaitlistEntryNotFound) {
+				return nil, AppError{
+					ErrCode:    errcode.ResourceNotFound,
+					HttpStatus: http.StatusNotFound,
+					Message:    fmt.Sprintf("waitlist entry %q not found", entryID),
+					err:        err,
+				}
+			}
+
+			return nil, fmt.Errorf("leave waitlist: %w", err)
+		}
+		setETag()
+
 		return nil, nil
 	})
 }
+
+// ReconfigureRoom changes the dimensions or the minimum distance of a room.
+// With dry_run it only reports the reserved and held seats that conflict
+// with the new settings. Otherwise the change is applied when there is no
+// conflict, or when it is forced.
+func (c *RoomController) ReconfigureRoom(w http.ResponseWriter, r *http.Request) {
+	easyHandler("reconfigure room", w, r, c.logger, func(ctx context.Context) (manager.RoomReconfiguration, error) {
+		room, err := c.room(r)
+		if err != nil {
+			return manager.RoomReconfiguration{}, err
+		}
+
+		req, err := decodeValid[request.RoomReconfiguration](r)
+		if err != nil {
+			return manager.RoomReconfiguration{}, err
+		}
+
+		cfg := room.Config(ctx)
+		if req.NumRows != nil {
+			cfg.NumRows = *req.NumRows
+		}
+		if req.NumCols != nil {
+			cfg.NumCols = *req.NumCols
+		}
+		if req.MinDistance != nil {
+			cfg.MinDistance = *req.MinDistance
+		}
+		if req.Layout != nil {
+			cfg.Layout = req.Layout
+			if req.NumRows == nil && len(req.Layout) > 0 {
+				cfg.NumRows = len(req.Layout)
+			}
+			if req.NumCols == nil && len(req.Layout) > 0 {
+				cfg.NumCols = len(req.Layout[0])
+			}
+		}
+
+		var reconfiguration manager.RoomReconfiguration
+		if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+			reconfiguration, err = room.PreviewReconfiguration(ctx, cfg)
+		} else {
+			reconfiguration, err = room.Reconfigure(ctx, cfg, req.Force)
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, manager.ErrInvalidRoomConfig):
+				return manager.RoomReconfiguration{}, AppError{
+					ErrCode:    errcode.InvalidParameters,
+					HttpStatus: http.StatusUnprocessableEntity,
+					Message:    err.Error(),
+					err:        err,
+				}
+			case errors.Is(err, manager.ErrReconfigurationConflict):
+				return manager.RoomReconfiguration{}, AppError{
+					ErrCode:    errcode.ResourceConflict,
+					HttpStatus: http.StatusConflict,
+					Message:    fmt.Sprintf("%d seats conflict with the new configuration, force it to apply anyway", len(reconfiguration.Conflicts)),
+					err:        toConflictErrors(reconfiguration.Conflicts),
+				}
+			}
+
+			return manager.RoomReconfiguration{}, fmt.Errorf("reconfigure room: %w", err)
+		}
+
+		return reconfiguration, nil
+	})
+}
+
+func toConflictErrors(conflicts []manager.RoomConflict) FieldErrors {
+	fErrs := make(FieldErrors, len(conflicts))
+	for i, conflict := range conflicts {
+		state := "reserved"
+		if conflict.HoldID != "" {
+			state = "held"
+		}
+
+		reason := "would be out of bound"
+		switch conflict.Code {
+		case manager.SeatErrorCodeInvalidDistance.String():
+			reason = "would violate min distance constraint"
+		case manager.SeatErrorCodeNotASeat.String():
+			reason = "would not be a seat"
+		}
+
+		fErrs[i] = FieldError{
+			Index:   i,
+			Field:   "position",
+			Code:    conflict.Code,
+			Message: fmt.Sprintf("position [%d,%d] %s by group %q %s", conflict.Row(), conflict.Col(), state, conflict.GroupID, reason),
+		}
+	}
+
+	return fErrs
+}
+
+func bookingAppError(bookingID string, err error) error {
+	if errors.Is(err, manager.ErrBookingNotFound) {
+		return AppError{
+			ErrCode:    errcode.ResourceNotFound,
+			HttpStatus: http.StatusNotFound,
+			Message:    fmt.Sprintf("booking %q not found", bookingID),
+			err:        err,
+		}
+	}
+
+	return err
+}
+
+func toSeats(groupSeats []request.GroupSeat) []manager.Seat {
+	seats := make([]manager.Seat, len(groupSeats))
+	for i := range groupSeats {
+		seats[i] = manager.Seat{
+			GroupID:    groupSeats[i].GroupID,
+			Coordinate: manager.Coordinate(*groupSeats[i].Position),
+		}
+	}
+
+	return seats
+}
+
+func seatAppError(err error) error {
+	if errors.Is(err, manager.ErrVersionMismatch) {
+		return AppError{
+			ErrCode:    errcode.ResourceConflict,
+			HttpStatus: http.StatusPreconditionFailed,
+			Message:    "room has changed",
+			err:        err,
+		}
+	}
+
+	var sErrs manager.SeatErrors
+	if sErr := (manager.SeatError{}); errors.As(err, &sErr) {
+		sErrs = manager.SeatErrors{sErr}
+	} else if !errors.As(err, &sErrs) {
+		return err
+	}
+
+	return AppError{
+		ErrCode:    errcode.InvalidParameters,
+		HttpStatus: http.StatusUnprocessableEntity,
+		err:        toFieldErrors(sErrs),
+	}
+}
+
+// moveAppError reports each violation against the from or the to position
+// of the offending move.
+func moveAppError(err error, moves []manager.SeatMove) error {
+	sErrs := manager.SeatErrors{}
+	if !errors.As(err, &sErrs) {
+		return seatAppError(err)
+	}
+
+	fErrs := toFieldErrors(sErrs)
+	for i, sErr := range sErrs {
+		fErrs[i].Field = "to"
+		if sErr.Seat().Coordinate == moves[sErr.Index()].From {
+			fErrs[i].Field = "from"
+		}
+	}
+
+	return AppError{
+		ErrCode:    errcode.InvalidParameters,
+		HttpStatus: http.StatusUnprocessableEntity,
+		err:        fErrs,
+	}
+}
+
+func toFieldErrors(sErrs manager.SeatErrors) FieldErrors {
+	fErrs := make(FieldErrors, len(sErrs))
+	for i, sErr := range sErrs {
+		field := ""
+		switch sErr.Code {
+		case manager.SeatErrorCodeOutOfBound, manager.SeatErrorCodeSeatTaken,
+			manager.SeatErrorCodeInvalidDistance, manager.SeatErrorCodeDuplicatedPosition,
+			manager.SeatErrorCodeNotReserved, manager.SeatErrorCodeNotInBooking, manager.SeatErrorCodeNotASeat:
+			field = "position"
+		case manager.SeatErrorCodeGroupIDNotFound:
+			field = "group_id"
+		default:
+			panic("unhandled default case")
+		}
+
+		fErrs[i] = FieldError{
+			Index:   sErr.Index(),
+			Field:   field,
+			Code:    sErr.Code.String(),
+			Message: sErr.Error(),
+		}
+	}
+
+	return fErrs
+}
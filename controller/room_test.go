@@ -9,10 +9,13 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/namlh/vulcanLabsOA/config"
 	"github.com/namlh/vulcanLabsOA/controller"
 	"github.com/namlh/vulcanLabsOA/manager"
+	"github.com/namlh/vulcanLabsOA/middleware"
+	"github.com/namlh/vulcanLabsOA/server"
 	"github.com/namlh/vulcanLabsOA/testing/assert"
 )
 
@@ -22,22 +25,11 @@ func TestRoomController_ListAvailableSeats(t *testing.T) {
 	ctx, cancel := context.WithCancel(ctx)
 	t.Cleanup(cancel)
 
-	logger := slog.Default()
-	cfg := config.Room{
-		NumRows:     4,
-		NumCols:     4,
-		MinDistance: 3,
-	}
-
-	groupManager := manager.NewGroupManager([]string{"abc"})
-	roomManager := manager.NewRoomManager(logger, &cfg, groupManager)
-	ctrl := controller.NewRoomController(logger, roomManager)
-
-	srv := httptest.NewServer(http.HandlerFunc(ctrl.ListAvailableSeats))
-	t.Cleanup(srv.Close)
+	srv := newTestServer(t, testRoom{groupIDs: []string{"abc"}})
 
 	testcases := []struct {
 		name       string
+		roomID     string
 		groupID    string
 		assertFunc func(t *testing.T, resp *http.Response)
 	}{
@@ -70,12 +62,28 @@ func TestRoomController_ListAvailableSeats(t *testing.T) {
 				assert.Equal(t, expect, string(buf))
 			},
 		},
+		{
+			name:   "fail/room_id not found",
+			roomID: "unknown",
+			assertFunc: func(t *testing.T, resp *http.Response) {
+				assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+				buf, err := io.ReadAll(resp.Body)
+				assert.NoError(t, err)
+				expect := `{"code":2,"message":"room \"unknown\" not found"}`
+				assert.Equal(t, expect, string(buf))
+			},
+		},
 	}
 
 	for _, tc := range testcases {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
-			req, err := http.NewRequestWithContext(ctx, "", srv.URL, nil)
+			roomID := tc.roomID
+			if roomID == "" {
+				roomID = "main"
+			}
+
+			req, err := http.NewRequestWithContext(ctx, "", srv.URL+"/api/v1/rooms/"+roomID+"/available-seats", nil)
 			assert.NoError(t, err)
 			if tc.groupID != "" {
 				q := req.URL.Query()
@@ -100,20 +108,7 @@ func TestRoomController_ReserveSeats(t *testing.T) {
 	ctx, cancel := context.WithCancel(ctx)
 	t.Cleanup(cancel)
 
-	logger := slog.Default()
-	cfg := config.Room{
-		NumRows:     4,
-		NumCols:     4,
-		MinDistance: 3,
-	}
-
-	groupManager := manager.NewGroupManager([]string{"abc", "xyz"})
-	roomManager := manager.NewRoomManager(logger, &cfg, groupManager)
-	ctrl := controller.NewRoomController(logger, roomManager)
-
-	mux := http.NewServeMux()
-	mux.HandleFunc("POST /api/seats/reservation", ctrl.ReserveSeats)
-	mux.HandleFunc("/api/available-seats", ctrl.ListAvailableSeats)
+	srv := newTestServer(t, testRoom{})
 
 	testcases := []struct {
 		name       string
@@ -129,10 +124,11 @@ func TestRoomController_ReserveSeats(t *testing.T) {
 			assertFunc: func(t *testing.T, url string, resp *http.Response) {
 				buf, err := io.ReadAll(resp.Body)
 				assert.NoError(t, err)
-				expect := `{"code":0,"message":"Success"}`
-				assert.Equal(t, expect, string(buf))
+				booking := decodeData[manager.Booking](t, string(buf))
+				assert.Equal(t, 32, len(booking.ID))
+				assert.Equal(t, manager.Seat{GroupID: "abc", Coordinate: manager.Coordinate{0, 1}}, booking.Seats[0])
 
-				req, err := http.NewRequestWithContext(ctx, "POST", url+"/api/available-seats", nil)
+				req, err := http.NewRequestWithContext(ctx, "GET", url+"/api/v1/rooms/main/available-seats", nil)
 				assert.NoError(t, err)
 				listSeatsResp, err := http.DefaultClient.Do(req)
 				assert.NoError(t, err)
@@ -142,7 +138,20 @@ func TestRoomController_ReserveSeats(t *testing.T) {
 
 				buf, err = io.ReadAll(listSeatsResp.Body)
 				assert.NoError(t, err)
-				expect = `{"code":0,"message":"Success","data":{"abc":[[0,0],[0,2],[0,3],[1,0],[1,1],[1,2],[1,3],[2,0],[2,1],[2,2],[2,3],[3,0],[3,1],[3,2],[3,3]],"xyz":[[1,3],[2,0],[2,2],[2,3],[3,0],[3,1],[3,2],[3,3]]}}`
+				expect := `{"code":0,"message":"Success","data":{"abc":[[0,0],[0,2],[0,3],[1,0],[1,1],[1,2],[1,3],[2,0],[2,1],[2,2],[2,3],[3,0],[3,1],[3,2],[3,3]],"xyz":[[1,3],[2,0],[2,2],[2,3],[3,0],[3,1],[3,2],[3,3]]}}`
+				assert.Equal(t, expect, string(buf))
+			},
+		},
+		{
+			name: "fail/empty seats_reservation",
+			req: func() io.Reader {
+				return strings.NewReader(`{"seats_reservation":[]}`)
+			},
+			assertFunc: func(t *testing.T, url string, resp *http.Response) {
+				assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+				buf, err := io.ReadAll(resp.Body)
+				assert.NoError(t, err)
+				expect := `{"code":1,"message":"Invalid parameters","details":{"seats_reservation":"seats_reservation must not be empty"}}`
 				assert.Equal(t, expect, string(buf))
 			},
 		},
@@ -151,10 +160,7 @@ func TestRoomController_ReserveSeats(t *testing.T) {
 	for _, tc := range testcases {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
-			srv := httptest.NewServer(mux)
-			t.Cleanup(srv.Close)
-
-			req, err := http.NewRequestWithContext(ctx, "POST", srv.URL+"/api/seats/reservation", tc.req())
+			req, err := http.NewRequestWithContext(ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", tc.req())
 			assert.NoError(t, err)
 
 			resp, err := http.DefaultClient.Do(req)
@@ -168,34 +174,107 @@ func TestRoomController_ReserveSeats(t *testing.T) {
 	}
 }
 
-func TestRoomController_CancelSeats(t *testing.T) {
+func TestRoomController_ReserveSeats_AllViolations(t *testing.T) {
 	t.Parallel()
 	ctx := context.Background()
-	ctx, cancel := context.WithCancel(ctx)
-	t.Cleanup(cancel)
 
-	logger := slog.Default()
-	cfg := config.Room{
-		NumRows:     4,
-		NumCols:     4,
-		MinDistance: 3,
+	srv := newTestServer(t, testRoom{})
+
+	status, body := doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation",
+		`{"seats_reservation":[{"group_id":"abc","position":[0,0]},{"group_id":"xyz","position":[0,1]},{"group_id":"abc","position":[0,0]},{"group_id":"foo","position":[4,0]},{"group_id":"abc","position":[3,3]}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	expect := `{"code":1,"message":"Invalid parameters","errors":[` +
+		`{"index":1,"field":"position","code":"invalid_distance","message":"position [0,1] at index 1 violate min distance constraint"},` +
+		`{"index":2,"field":"position","code":"duplicated_position","message":"position [0,0] at index 2 is duplicated"},` +
+		`{"index":3,"field":"group_id","code":"group_id_not_found","message":"group_id foo at index 3 not found"},` +
+		`{"index":3,"field":"position","code":"out_of_bound","message":"position [4,0] at index 3 out of bound"}]}`
+	assert.Equal(t, expect, body)
+
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/cancellation",
+		`{"seats_cancellation":[{"position":[0,0]},{"position":[5,5]}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	expect = `{"code":1,"message":"Invalid parameters","errors":[` +
+		`{"index":0,"field":"position","code":"not_reserved","message":"position [0,0] at index 0 did not get reserved"},` +
+		`{"index":1,"field":"position","code":"out_of_bound","message":"position [5,5] at index 1 out of bound"}]}`
+	assert.Equal(t, expect, body)
+
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/cancellation", `{"seats_cancellation":[]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","details":{"seats_cancellation":"seats_cancellation must not be empty"}}`, body)
+}
+
+func TestRoomController_ReserveSeats_BestEffort(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	srv := newTestServer(t, testRoom{})
+
+	status, body := doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation",
+		`{"mode":"partial","seats_reservation":[{"group_id":"abc","position":[0,0]}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","details":{"mode":"mode must be atomic or best_effort"}}`, body)
+
+	// earlier accepted seats constrain later ones
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation",
+		`{"mode":"best_effort","seats_reservation":[{"group_id":"abc","position":[0,0]},{"group_id":"xyz","position":[0,1]},{"group_id":"xyz","position":[3,3]},{"group_id":"abc","position":[3,2]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+	result := decodeData[controller.BestEffortReservation](t, body)
+	assert.Equal(t, 32, len(result.BookingID))
+	assert.Equal(t, 4, len(result.Results))
+	for i, accepted := range []bool{true, false, true, false} {
+		assert.Equal(t, accepted, result.Results[i].Accepted)
+		assert.Equal(t, !accepted, len(result.Results[i].Errors) > 0)
 	}
+	assert.Equal(t, "invalid_distance", result.Results[3].Errors[0].Code)
 
-	groupManager := manager.NewGroupManager([]string{"abc", "xyz"})
-	roomManager := manager.NewRoomManager(logger, &cfg, groupManager)
-	ctrl := controller.NewRoomController(logger, roomManager)
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/bookings/"+result.BookingID, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"code":0,"message":"Success","data":{"booking_id":"`+result.BookingID+`","seats":[{"group_id":"abc","position":[0,0]},{"group_id":"xyz","position":[3,3]}]}}`, body)
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("POST /api/seats/reservation", ctrl.ReserveSeats)
-	mux.HandleFunc("POST /api/seats/cancellation", ctrl.CancelSeats)
-	mux.HandleFunc("/api/available-seats", ctrl.ListAvailableSeats)
+	// nothing is booked when every seat is rejected
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation",
+		`{"mode":"best_effort","seats_reservation":[{"group_id":"abc","position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+	expect := `{"code":0,"message":"Success","data":{"results":[{"index":0,"group_id":"abc","position":[0,0],"accepted":false,"errors":[` +
+		`{"index":0,"field":"position","code":"seat_taken","message":"position [0,0] at index 0 has already been taken"}]}]}}`
+	assert.Equal(t, expect, body)
+}
 
-	srv := httptest.NewServer(mux)
-	t.Cleanup(srv.Close)
+func TestRoomController_ValidateReservation(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	srv := newTestServer(t, testRoom{})
+
+	status, body := doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation:validate",
+		`{"seats_reservation":[{"group_id":"abc","position":[0,0]},{"group_id":"abc","position":[0,1]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"code":0,"message":"Success","data":{"valid":true}}`, body)
+
+	// seats of the same batch are checked against each other
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation?dry_run=true",
+		`{"seats_reservation":[{"group_id":"abc","position":[0,0]},{"group_id":"xyz","position":[1,1]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+	expect := `{"code":0,"message":"Success","data":{"valid":false,"errors":[` +
+		`{"index":1,"field":"position","code":"invalid_distance","message":"position [1,1] at index 1 violate min distance constraint"}]}}`
+	assert.Equal(t, expect, body)
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/reservations", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"code":0,"message":"Success"}`, body)
+}
+
+func TestRoomController_CancelSeats(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	srv := newTestServer(t, testRoom{})
 
 	{
 		var reqBody io.Reader = strings.NewReader(`{"seats_reservation":[{"group_id":"abc","position":[0,1]}]}`)
-		req, err := http.NewRequestWithContext(ctx, "POST", srv.URL+"/api/seats/reservation", reqBody)
+		req, err := http.NewRequestWithContext(ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", reqBody)
 		assert.NoError(t, err)
 
 		resp, err := http.DefaultClient.Do(req)
@@ -204,12 +283,13 @@ func TestRoomController_CancelSeats(t *testing.T) {
 
 		buf, err := io.ReadAll(resp.Body)
 		assert.NoError(t, err)
-		assert.Equal(t, `{"code":0,"message":"Success"}`, string(buf))
+		booking := decodeData[manager.Booking](t, string(buf))
+		assert.Equal(t, 1, len(booking.Seats))
 	}
 
 	{
 		var reqBody io.Reader = strings.NewReader(`{"seats_cancellation":[{"position":[0,1]}]}`)
-		req, err := http.NewRequestWithContext(ctx, "POST", srv.URL+"/api/seats/cancellation", reqBody)
+		req, err := http.NewRequestWithContext(ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/cancellation", reqBody)
 		assert.NoError(t, err)
 
 		resp, err := http.DefaultClient.Do(req)
@@ -222,7 +302,7 @@ func TestRoomController_CancelSeats(t *testing.T) {
 	}
 
 	{
-		req, err := http.NewRequestWithContext(ctx, "POST", srv.URL+"/api/available-seats", nil)
+		req, err := http.NewRequestWithContext(ctx, "GET", srv.URL+"/api/v1/rooms/main/available-seats", nil)
 		assert.NoError(t, err)
 		resp, err := http.DefaultClient.Do(req)
 		assert.NoError(t, err)
@@ -231,8 +311,8 @@ func TestRoomController_CancelSeats(t *testing.T) {
 		buf, err := io.ReadAll(resp.Body)
 		assert.NoError(t, err)
 		arr := make([][2]int, 0)
-		for i := 0; i < cfg.NumRows; i++ {
-			for j := 0; j < cfg.NumCols; j++ {
+		for i := 0; i < 4; i++ {
+			for j := 0; j < 4; j++ {
 				arr = append(arr, [2]int{i, j})
 			}
 		}
@@ -243,3 +323,800 @@ func TestRoomController_CancelSeats(t *testing.T) {
 		assert.Equal(t, string(expect), string(buf))
 	}
 }
+
+func TestRoomController_ListRooms(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	srv := newTestServer(t, testRoom{rooms: []config.Room{
+		{ID: "main", NumRows: 8, NumCols: 8, MinDistance: 7},
+		{ID: "studio", NumRows: 4, NumCols: 6, MinDistance: 3, DistanceMetric: "row"},
+	}})
+
+	req, err := http.NewRequestWithContext(ctx, "", srv.URL+"/api/v1/rooms", nil)
+	assert.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	assert.Equal(t, 200, resp.StatusCode)
+	buf, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	expect := `{"code":0,"message":"Success","data":[{"id":"main","num_rows":8,"num_cols":8,"min_distance":7,"distance_metric":"manhattan"},{"id":"studio","num_rows":4,"num_cols":6,"min_distance":3,"distance_metric":"row"}]}`
+	assert.Equal(t, expect, string(buf))
+}
+
+func TestRoomController_MoveSeats(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	srv := newTestServer(t, testRoom{})
+
+	status, body := doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"abc","position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+	booking := decodeData[manager.Booking](t, body)
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"xyz","position":[3,3]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	// swap the seats of two groups
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/move", `{"seats_move":[{"from":[0,0],"to":[3,3]},{"from":[3,3],"to":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/bookings/"+booking.ID, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"code":0,"message":"Success","data":{"booking_id":"`+booking.ID+`","seats":[{"group_id":"abc","position":[3,3]}]}}`, body)
+
+	// too close to abc at [3,3]
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/move", `{"seats_move":[{"from":[0,0],"to":[2,2]},{"from":[1,1],"to":[1,2]}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","errors":[{"index":1,"field":"from","code":"not_reserved","message":"position [1,1] at index 1 did not get reserved"}]}`, body)
+
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/move", `{"seats_move":[{"from":[0,0],"to":[2,2]}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","errors":[{"index":0,"field":"to","code":"invalid_distance","message":"position [2,2] at index 0 violate min distance constraint"}]}`, body)
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/reservations", "")
+	assert.Equal(t, http.StatusOK, status)
+	reservations := decodeData[[]manager.Reservation](t, body)
+	assert.Equal(t, 2, len(reservations))
+	assert.Equal(t, manager.Seat{GroupID: "xyz", Coordinate: manager.Coordinate{0, 0}}, reservations[0].Seat)
+	assert.Equal(t, manager.Seat{GroupID: "abc", Coordinate: manager.Coordinate{3, 3}}, reservations[1].Seat)
+
+	// valid once abc has moved away as well
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/move", `{"seats_move":[{"from":[0,0],"to":[2,2]},{"from":[3,3],"to":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+	moved := decodeData[[]manager.Reservation](t, body)
+	assert.Equal(t, 2, len(moved))
+	assert.Equal(t, manager.Seat{GroupID: "xyz", Coordinate: manager.Coordinate{2, 2}}, moved[0].Seat)
+	assert.Equal(t, manager.Seat{GroupID: "abc", Coordinate: manager.Coordinate{0, 0}}, moved[1].Seat)
+}
+
+func TestRoomController_ApplyTransaction(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	srv := newTestServer(t, testRoom{})
+
+	status, _ := doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"abc","position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, body := doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/transaction", `{"operations":[{"type":"hold","position":[0,0]}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","errors":[{"index":0,"field":"type","code":"invalid_value","message":"type at index 0 must be reserve or cancel"}]}`, body)
+
+	// every malformed item is reported at once
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation",
+		`{"seats_reservation":[{"position":[0,1]},{"group_id":"abc","position":[-1,0]},{"group_id":"abc"},{"group_id":"abc","position":[0,-2]}],"mode":"all"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","details":{"mode":"mode must be atomic or best_effort"},"errors":[`+
+		`{"index":0,"field":"group_id","code":"required","message":"group_id at index 0 must not be empty"},`+
+		`{"index":1,"field":"position","code":"out_of_bound","message":"position at index 1 must not be negative"},`+
+		`{"index":2,"field":"position","code":"required","message":"position at index 2 must not be empty"},`+
+		`{"index":3,"field":"position","code":"out_of_bound","message":"position at index 3 must not be negative"}]}`, body)
+
+	// the first failing operation is reported and nothing changes
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/transaction",
+		`{"operations":[{"type":"cancel","position":[0,0]},{"type":"reserve","group_id":"abc","position":[3,3]},{"type":"reserve","group_id":"xyz","position":[3,2]},{"type":"cancel","position":[2,2]}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","errors":[{"index":3,"field":"position","code":"not_reserved","message":"position [2,2] at index 3 did not get reserved"}]}`, body)
+
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/transaction",
+		`{"operations":[{"type":"cancel","position":[0,0]},{"type":"reserve","group_id":"abc","position":[3,3]},{"type":"reserve","group_id":"xyz","position":[3,2]}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","errors":[{"index":2,"field":"position","code":"invalid_distance","message":"position [3,2] at index 2 violate min distance constraint"}]}`, body)
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/reservations", "")
+	assert.Equal(t, http.StatusOK, status)
+	reservations := decodeData[[]manager.Reservation](t, body)
+	assert.Equal(t, 1, len(reservations))
+	assert.Equal(t, manager.Seat{GroupID: "abc", Coordinate: manager.Coordinate{0, 0}}, reservations[0].Seat)
+
+	// distance is checked against the final state, so reserving next to a
+	// seat cancelled later in the same transaction is fine
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/transaction",
+		`{"operations":[{"type":"reserve","group_id":"xyz","position":[0,1]},{"type":"cancel","position":[0,0]},{"type":"reserve","group_id":"xyz","position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+	mutation := decodeData[manager.Mutation](t, body)
+	assert.Equal(t, 1, len(mutation.Cancelled))
+	assert.Equal(t, manager.Coordinate{0, 0}, mutation.Cancelled[0])
+	assert.Equal(t, 2, len(mutation.Reserved))
+	assert.Equal(t, mutation.Reserved[0].BookingID, mutation.Reserved[1].BookingID)
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/reservations", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"code":0,"message":"Success","data":[{"group_id":"xyz","position":[0,0],"booking_id":"`+mutation.Reserved[0].BookingID+`"},{"group_id":"xyz","position":[0,1],"booking_id":"`+mutation.Reserved[0].BookingID+`"}]}`, body)
+}
+
+func TestRoomController_Versioning(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	srv := newTestServer(t, testRoom{})
+
+	do := func(method, path, header, value, body string) (*http.Response, string) {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, method, srv.URL+path, strings.NewReader(body))
+		assert.NoError(t, err)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		return resp, string(data)
+	}
+
+	resp, _ := do("GET", "/api/v1/rooms/main/available-seats", "", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+	assert.Equal(t, true, etag != "")
+
+	resp, _ = do("GET", "/api/v1/rooms/main/available-seats", "If-None-Match", etag, "")
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	assert.Equal(t, etag, resp.Header.Get("ETag"))
+
+	resp, _ = do("POST", "/api/v1/rooms/main/seats/reservation", "If-Match", etag, `{"seats_reservation":[{"group_id":"abc","position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the room changed since etag was issued
+	resp, _ = do("POST", "/api/v1/rooms/main/seats/reservation", "If-Match", etag, `{"seats_reservation":[{"group_id":"abc","position":[3,3]}]}`)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp, _ = do("POST", "/api/v1/rooms/main/seats/cancellation", "If-Match", etag, `{"seats_cancellation":[{"position":[0,0]}]}`)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp, _ = do("GET", "/api/v1/rooms/main/available-seats", "If-None-Match", etag, "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	newETag := resp.Header.Get("ETag")
+	assert.Equal(t, true, newETag != etag)
+
+	resp, _ = do("POST", "/api/v1/rooms/main/seats/cancellation", "If-Match", newETag, `{"seats_cancellation":[{"position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// a new group changes the listing without changing the room
+	resp, _ = do("GET", "/api/v1/rooms/main/available-seats", "", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	etag = resp.Header.Get("ETag")
+	resp, _ = do("POST", "/api/v1/groups", "", "", `{"id":"def"}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, _ = do("GET", "/api/v1/rooms/main/available-seats", "If-None-Match", etag, "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, true, resp.Header.Get("ETag") != etag)
+
+	// while the room version of the tag still matches
+	resp, _ = do("POST", "/api/v1/rooms/main/seats/reservation", "If-Match", etag, `{"seats_reservation":[{"group_id":"def","position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// every other mutation is conditional as well
+	room, err := srv.rooms.GetRoom(ctx, "main")
+	assert.NoError(t, err)
+	bookingID := room.ListReservations(ctx, "def")[0].BookingID
+	resp, _ = do("POST", "/api/v1/rooms/main/bookings/"+bookingID+"/cancel", "If-Match", etag, "")
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp, _ = do("POST", "/api/v1/rooms/main/seats/move", "If-Match", etag, `{"seats_move":[{"from":[0,0],"to":[3,3]}]}`)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp, _ = do("POST", "/api/v1/rooms/main/seats/transaction", "If-Match", etag, `{"operations":[{"type":"cancel","position":[0,0]}]}`)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp, _ = do("POST", "/api/v1/rooms/main/seats/allocate", "If-Match", etag, `{"group_id":"xyz","count":1}`)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp, _ = do("POST", "/api/v1/rooms/main/seats/hold", "If-Match", etag, `{"seats_hold":[{"group_id":"xyz","position":[3,3]}]}`)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp, _ = do("POST", "/api/v1/rooms/main/waitlist", "If-Match", etag, `{"group_id":"xyz","count":1}`)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	assert.Equal(t, 1, len(room.ListReservations(ctx, "")))
+	assert.Equal(t, 0, len(room.ListWaitlist(ctx)))
+
+	// every mutation answers with the tag of the state it left
+	resp, _ = do("GET", "/api/v1/rooms/main/available-seats", "", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = do("POST", "/api/v1/rooms/main/bookings/"+bookingID+"/cancel", "If-Match", resp.Header.Get("ETag"), "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	etag = resp.Header.Get("ETag")
+
+	resp, body := do("POST", "/api/v1/rooms/main/seats/hold", "If-Match", etag, `{"seats_hold":[{"group_id":"xyz","position":[3,3]}]}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	hold := decodeData[manager.Hold](t, body)
+	holdETag := resp.Header.Get("ETag")
+	assert.Equal(t, true, holdETag != etag)
+	resp, _ = do("POST", "/api/v1/rooms/main/holds/"+hold.ID+"/confirm", "If-Match", etag, "")
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp, _ = do("POST", "/api/v1/rooms/main/seats/hold", "If-Match", etag, `{"seats_hold":[{"group_id":"xyz","position":[0,3]}]}`)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp, _ = do("POST", "/api/v1/rooms/main/holds/"+hold.ID+"/confirm", "If-Match", holdETag, "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = do("POST", "/api/v1/rooms/main/waitlist", "If-Match", resp.Header.Get("ETag"), `{"group_id":"xyz","count":1}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestRoomController_HoldSeats(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	holdSeats := func(t *testing.T, srv testServer) manager.Hold {
+		status, body := doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/hold", `{"seats_hold":[{"group_id":"abc","position":[0,1]}]}`)
+		assert.Equal(t, http.StatusOK, status)
+
+		hold := decodeData[manager.Hold](t, body)
+		assert.Equal(t, 1, len(hold.Seats))
+		return hold
+	}
+
+	t.Run("success/hold then confirm", func(t *testing.T) {
+		t.Parallel()
+		srv := newTestServer(t, testRoom{})
+		hold := holdSeats(t, srv)
+
+		status, body := doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"xyz","position":[1,1]}]}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, status)
+		assert.Equal(t, `{"code":1,"message":"Invalid parameters","errors":[{"index":0,"field":"position","code":"invalid_distance","message":"position [1,1] at index 0 violate min distance constraint"}]}`, body)
+
+		status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/holds/"+hold.ID+"/confirm", "")
+		assert.Equal(t, http.StatusOK, status)
+		booking := decodeData[manager.Booking](t, body)
+		assert.Equal(t, hold.Seats[0], booking.Seats[0])
+
+		status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/holds/"+hold.ID+"/confirm", "")
+		assert.Equal(t, http.StatusNotFound, status)
+
+		status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"abc","position":[0,1]}]}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, status)
+		assert.Equal(t, `{"code":1,"message":"Invalid parameters","errors":[{"index":0,"field":"position","code":"seat_taken","message":"position [0,1] at index 0 has already been taken"}]}`, body)
+	})
+
+	t.Run("fail/hold expired", func(t *testing.T) {
+		t.Parallel()
+		srv := newTestServer(t, testRoom{hold: config.Hold{TTL: time.Millisecond}})
+		hold := holdSeats(t, srv)
+		time.Sleep(5 * time.Millisecond)
+
+		status, _ := doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/holds/"+hold.ID+"/confirm", "")
+		assert.Equal(t, http.StatusGone, status)
+
+		status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"xyz","position":[0,1]}]}`)
+		assert.Equal(t, http.StatusOK, status)
+	})
+}
+
+func TestRoomController_Bookings(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	srv := newTestServer(t, testRoom{})
+
+	status, body := doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"abc","position":[0,0]},{"group_id":"abc","position":[0,1]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+	abcBooking := decodeData[manager.Booking](t, body)
+
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"xyz","position":[3,3]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+	xyzBooking := decodeData[manager.Booking](t, body)
+
+	bookingURL := srv.URL + "/api/v1/rooms/main/bookings/" + abcBooking.ID
+
+	status, body = doRequest(t, ctx, "GET", bookingURL, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"code":0,"message":"Success","data":{"booking_id":"`+abcBooking.ID+`","seats":[{"group_id":"abc","position":[0,0]},{"group_id":"abc","position":[0,1]}]}}`, body)
+
+	// seats of another booking cannot be cancelled
+	status, body = doRequest(t, ctx, "POST", bookingURL+"/cancel", `{"seats_cancellation":[{"position":[3,3]}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","errors":[{"index":0,"field":"position","code":"not_in_booking","message":"position [3,3] at index 0 does not belong to the booking"}]}`, body)
+
+	status, _ = doRequest(t, ctx, "POST", bookingURL+"/cancel", `{"seats_cancellation":[{"position":[0,1]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, body = doRequest(t, ctx, "GET", bookingURL, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"code":0,"message":"Success","data":{"booking_id":"`+abcBooking.ID+`","seats":[{"group_id":"abc","position":[0,0]}]}}`, body)
+
+	status, _ = doRequest(t, ctx, "POST", bookingURL+"/cancel", "")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = doRequest(t, ctx, "GET", bookingURL, "")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/bookings/"+xyzBooking.ID, "")
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestRoomController_AllocateSeats(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	srv := newTestServer(t, testRoom{})
+
+	status, _ := doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"xyz","position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, body := doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/allocate", `{"group_id":"abc","count":3}`)
+	assert.Equal(t, http.StatusOK, status)
+	booking := decodeData[manager.Booking](t, body)
+	assert.Equal(t, 3, len(booking.Seats))
+	for i, col := range []int{1, 2, 3} {
+		assert.Equal(t, manager.Seat{GroupID: "abc", Coordinate: manager.Coordinate{2, col}}, booking.Seats[i])
+	}
+
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/allocate", `{"group_id":"xyz","count":5}`)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, `{"code":1,"message":"cannot allocate 5 seats for group \"xyz\""}`, body)
+
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/allocate", `{"group_id":"unknown","count":1}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","details":{"group_id":"group_id not found"}}`, body)
+}
+
+func TestRoomController_Waitlist(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	eventBus := manager.NewEventBus(0)
+	sub := eventBus.Subscribe("main")
+	srv := newTestServer(t, testRoom{hold: config.Hold{TTL: 10 * time.Millisecond}, eventBus: eventBus})
+	waitlistURL := srv.URL + "/api/v1/rooms/main/waitlist"
+
+	nextFulfilled := func(t *testing.T) manager.Event {
+		t.Helper()
+		for {
+			select {
+			case event := <-sub.C():
+				if event.Type == manager.EventWaitlistFulfilled {
+					return event
+				}
+			case <-time.After(time.Second):
+				t.Fatal("no waitlist_fulfilled event")
+			}
+		}
+	}
+
+	status, _ := doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"xyz","position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	// [0,1] is too close to [0,0] for abc
+	status, body := doRequest(t, ctx, "POST", waitlistURL, `{"group_id":"abc","positions":[[0,1]]}`)
+	assert.Equal(t, http.StatusOK, status)
+	first := decodeData[manager.WaitlistEntry](t, body)
+	assert.Equal(t, "", first.BookingID)
+
+	// served right away although an earlier entry is pending
+	status, body = doRequest(t, ctx, "POST", waitlistURL, `{"group_id":"xyz","positions":[[3,3]]}`)
+	assert.Equal(t, http.StatusOK, status)
+	served := decodeData[manager.WaitlistEntry](t, body)
+	assert.Equal(t, true, served.BookingID != "")
+	assert.Equal(t, served.ID, nextFulfilled(t).Waitlist.ID)
+
+	status, body = doRequest(t, ctx, "GET", waitlistURL, "")
+	assert.Equal(t, http.StatusOK, status)
+	entries := decodeData[[]manager.WaitlistEntry](t, body)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, first.ID, entries[0].ID)
+
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/cancellation", `{"seats_cancellation":[{"position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	event := nextFulfilled(t)
+	assert.Equal(t, first.ID, event.Waitlist.ID)
+	assert.Equal(t, true, event.Waitlist.BookingID != "")
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/reservations?group_id=abc", "")
+	assert.Equal(t, http.StatusOK, status)
+	reservations := decodeData[[]manager.Reservation](t, body)
+	assert.Equal(t, 1, len(reservations))
+	assert.Equal(t, manager.Coordinate{0, 1}, reservations[0].Coordinate)
+	assert.Equal(t, event.Waitlist.BookingID, reservations[0].BookingID)
+
+	status, body = doRequest(t, ctx, "GET", waitlistURL, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 0, len(decodeData[[]manager.WaitlistEntry](t, body)))
+
+	// freed by an expired hold
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/hold", `{"seats_hold":[{"group_id":"abc","position":[0,2]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+	hold := decodeData[manager.Hold](t, body)
+
+	status, body = doRequest(t, ctx, "POST", waitlistURL, `{"group_id":"abc","positions":[[0,2]]}`)
+	assert.Equal(t, http.StatusOK, status)
+	held := decodeData[manager.WaitlistEntry](t, body)
+	assert.Equal(t, "", held.BookingID)
+
+	time.Sleep(20 * time.Millisecond)
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/holds/"+hold.ID+"/confirm", "")
+	assert.Equal(t, http.StatusGone, status)
+	assert.Equal(t, held.ID, nextFulfilled(t).Waitlist.ID)
+
+	// leaving
+	status, body = doRequest(t, ctx, "POST", waitlistURL, `{"group_id":"abc","positions":[[0,2]]}`)
+	assert.Equal(t, http.StatusOK, status)
+	pending := decodeData[manager.WaitlistEntry](t, body)
+
+	status, _ = doRequest(t, ctx, "DELETE", waitlistURL+"/"+pending.ID, "")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, body = doRequest(t, ctx, "DELETE", waitlistURL+"/"+pending.ID, "")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, `{"code":2,"message":"waitlist entry \"`+pending.ID+`\" not found"}`, body)
+
+	// invalid requests
+	status, body = doRequest(t, ctx, "POST", waitlistURL, `{"group_id":"abc","count":1,"positions":[[0,2]]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","details":{"count":"only one of count and positions must be set"}}`, body)
+
+	status, body = doRequest(t, ctx, "POST", waitlistURL, `{"group_id":"abc","count":17}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","details":{"count":"count must not exceed the seats of the room"}}`, body)
+
+	status, body = doRequest(t, ctx, "POST", waitlistURL, `{"group_id":"abc","positions":[[4,0]]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","errors":[{"index":0,"field":"position","code":"out_of_bound","message":"position [4,0] at index 0 out of bound"}]}`, body)
+}
+
+func TestRoomController_WaitlistSkipAhead(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	srv := newTestServer(t, testRoom{})
+	waitlistURL := srv.URL + "/api/v1/rooms/main/waitlist"
+
+	status, _ := doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"xyz","position":[0,0]},{"group_id":"abc","position":[3,3]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	// [0,1] stays too close to [0,0], [3,2] is freed by the cancellation
+	status, body := doRequest(t, ctx, "POST", waitlistURL, `{"group_id":"abc","positions":[[0,1]]}`)
+	assert.Equal(t, http.StatusOK, status)
+	head := decodeData[manager.WaitlistEntry](t, body)
+	status, body = doRequest(t, ctx, "POST", waitlistURL, `{"group_id":"xyz","positions":[[3,2]]}`)
+	assert.Equal(t, http.StatusOK, status)
+	next := decodeData[manager.WaitlistEntry](t, body)
+	assert.Equal(t, "", next.BookingID)
+
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/cancellation", `{"seats_cancellation":[{"position":[3,3]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	// the later entry is served and the head keeps waiting
+	status, body = doRequest(t, ctx, "GET", waitlistURL, "")
+	assert.Equal(t, http.StatusOK, status)
+	entries := decodeData[[]manager.WaitlistEntry](t, body)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, head.ID, entries[0].ID)
+
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"abc","position":[3,2]}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+}
+
+func TestRoomController_ReconfigureRoom(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	srv := newTestServer(t, testRoom{
+		rooms: []config.Room{{ID: "main", NumRows: 4, NumCols: 4, MinDistance: 2}},
+	})
+	configURL := srv.URL + "/api/v1/admin/rooms/main/config"
+
+	status, _ := doRequest(t, ctx, "POST", configURL, `{"num_rows":3}`)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"abc","position":[0,0]},{"group_id":"xyz","position":[0,2]},{"group_id":"xyz","position":[3,3]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	numRows := func(t *testing.T) int {
+		t.Helper()
+		status, body := doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms", "")
+		assert.Equal(t, http.StatusOK, status)
+		return decodeData[[]config.Room](t, body)[0].NumRows
+	}
+
+	t.Run("preview", func(t *testing.T) {
+		status, body := doRequestWithHeader(t, ctx, adminHeader, "POST", configURL+"?dry_run=true", `{"num_rows":3,"min_distance":3}`)
+		assert.Equal(t, http.StatusOK, status)
+		reconfiguration := decodeData[manager.RoomReconfiguration](t, body)
+		assert.Equal(t, false, reconfiguration.Applied)
+		assert.Equal(t, 3, reconfiguration.Room.NumRows)
+		assert.Equal(t, 4, reconfiguration.Room.NumCols)
+		assert.Equal(t, 3, len(reconfiguration.Conflicts))
+		for i, code := range []string{"invalid_distance", "invalid_distance", "out_of_bound"} {
+			assert.Equal(t, code, reconfiguration.Conflicts[i].Code)
+		}
+		assert.Equal(t, manager.Coordinate{3, 3}, reconfiguration.Conflicts[2].Coordinate)
+		assert.Equal(t, 4, numRows(t))
+	})
+
+	t.Run("conflicts without force", func(t *testing.T) {
+		status, body := doRequestWithHeader(t, ctx, adminHeader, "POST", configURL, `{"num_rows":3}`)
+		assert.Equal(t, http.StatusConflict, status)
+		assert.Equal(t, `{"code":3,"message":"1 seats conflict with the new configuration, force it to apply anyway","errors":[{"index":0,"field":"position","code":"out_of_bound","message":"position [3,3] reserved by group \"xyz\" would be out of bound"}]}`, body)
+		assert.Equal(t, 4, numRows(t))
+	})
+
+	t.Run("conflict free", func(t *testing.T) {
+		status, body := doRequestWithHeader(t, ctx, adminHeader, "POST", configURL, `{"num_cols":5}`)
+		assert.Equal(t, http.StatusOK, status)
+		reconfiguration := decodeData[manager.RoomReconfiguration](t, body)
+		assert.Equal(t, true, reconfiguration.Applied)
+		assert.Equal(t, 0, len(reconfiguration.Conflicts))
+
+		status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/available-seats?group_id=xyz", "")
+		assert.Equal(t, http.StatusOK, status)
+		available := decodeData[map[string][]manager.Coordinate](t, body)["xyz"]
+		assert.Equal(t, manager.Coordinate{3, 4}, available[len(available)-1])
+	})
+
+	t.Run("forced", func(t *testing.T) {
+		status, body := doRequestWithHeader(t, ctx, adminHeader, "POST", configURL, `{"num_rows":3,"min_distance":3,"force":true}`)
+		assert.Equal(t, http.StatusOK, status)
+		reconfiguration := decodeData[manager.RoomReconfiguration](t, body)
+		assert.Equal(t, true, reconfiguration.Applied)
+		assert.Equal(t, 3, len(reconfiguration.Conflicts))
+		assert.Equal(t, 3, numRows(t))
+
+		// seats out of bound are cancelled, seats too close are kept
+		status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/reservations", "")
+		assert.Equal(t, http.StatusOK, status)
+		reservations := decodeData[[]manager.Reservation](t, body)
+		assert.Equal(t, 2, len(reservations))
+		assert.Equal(t, manager.Coordinate{0, 0}, reservations[0].Coordinate)
+		assert.Equal(t, manager.Coordinate{0, 2}, reservations[1].Coordinate)
+
+		status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"abc","position":[1,2]}]}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, status)
+		assert.Equal(t, `{"code":1,"message":"Invalid parameters","errors":[{"index":0,"field":"position","code":"invalid_distance","message":"position [1,2] at index 0 violate min distance constraint"}]}`, body)
+	})
+
+	t.Run("layout", func(t *testing.T) {
+		status, body := doRequestWithHeader(t, ctx, adminHeader, "POST", configURL, `{"layout":["SSSSS","SSSSS","SSSXX"],"min_distance":2}`)
+		assert.Equal(t, http.StatusOK, status)
+		reconfiguration := decodeData[manager.RoomReconfiguration](t, body)
+		assert.Equal(t, true, reconfiguration.Applied)
+		assert.Equal(t, 3, len(reconfiguration.Room.Layout))
+
+		status, body = doRequestWithHeader(t, ctx, adminHeader, "POST", configURL, `{"num_cols":4}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, status)
+		assert.Equal(t, `{"code":1,"message":"invalid room configuration: the room has a layout, a new layout must be set to change its dimensions"}`, body)
+
+		status, body = doRequestWithHeader(t, ctx, adminHeader, "POST", configURL, `{"layout":["SSSS","SSSS","SSSS","SSXX"],"min_distance":2}`)
+		assert.Equal(t, http.StatusOK, status)
+		reconfiguration = decodeData[manager.RoomReconfiguration](t, body)
+		assert.Equal(t, true, reconfiguration.Applied)
+		assert.Equal(t, 4, reconfiguration.Room.NumCols)
+		assert.Equal(t, 4, numRows(t))
+	})
+
+	t.Run("layout voiding a reserved seat", func(t *testing.T) {
+		layout := `"layout":["SSXS","SSSS","SSSS","SSXX"],"min_distance":2`
+		status, body := doRequestWithHeader(t, ctx, adminHeader, "POST", configURL, `{`+layout+`}`)
+		assert.Equal(t, http.StatusConflict, status)
+		assert.Equal(t, `{"code":3,"message":"1 seats conflict with the new configuration, force it to apply anyway","errors":[{"index":0,"field":"position","code":"not_a_seat","message":"position [0,2] reserved by group \"xyz\" would not be a seat"}]}`, body)
+
+		status, body = doRequestWithHeader(t, ctx, adminHeader, "POST", configURL, `{`+layout+`,"force":true}`)
+		assert.Equal(t, http.StatusOK, status)
+		reconfiguration := decodeData[manager.RoomReconfiguration](t, body)
+		assert.Equal(t, true, reconfiguration.Applied)
+
+		// the reservation on the void is cancelled
+		status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/reservations", "")
+		assert.Equal(t, http.StatusOK, status)
+		reservations := decodeData[[]manager.Reservation](t, body)
+		assert.Equal(t, 1, len(reservations))
+		assert.Equal(t, manager.Coordinate{0, 0}, reservations[0].Coordinate)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		status, body := doRequestWithHeader(t, ctx, adminHeader, "POST", configURL, `{"num_rows":0}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, status)
+		assert.Equal(t, `{"code":1,"message":"Invalid parameters","details":{"num_rows":"num_rows must be greater than 0"}}`, body)
+
+		status, _ = doRequestWithHeader(t, ctx, adminHeader, "POST", srv.URL+"/api/v1/admin/rooms/unknown/config", `{"num_rows":1}`)
+		assert.Equal(t, http.StatusNotFound, status)
+	})
+}
+
+func TestRoomController_SeatMap(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	srv := newTestServer(t, testRoom{
+		rooms: []config.Room{{
+			ID:          "main",
+			NumRows:     2,
+			NumCols:     4,
+			MinDistance: 3,
+			Layout: []string{
+				"SS_S",
+				"SXSS",
+			},
+		}},
+	})
+
+	status, body := doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/available-seats?group_id=abc", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"code":0,"message":"Success","data":{"abc":[[0,0],[0,1],[0,3],[1,0],[1,2],[1,3]]}}`, body)
+
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"abc","position":[0,2]}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, `{"code":1,"message":"Invalid parameters","errors":[{"index":0,"field":"position","code":"not_a_seat","message":"position [0,2] at index 0 is not a seat"}]}`, body)
+
+	status, _ = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"xyz","position":[0,1]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	// the aisle still counts towards the distance
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/available-seats?group_id=abc", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"code":0,"message":"Success","data":{"abc":[[1,3]]}}`, body)
+}
+
+func TestRoomController_ListReservations(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	srv := newTestServer(t, testRoom{})
+
+	status, body := doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/reservations", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"code":0,"message":"Success"}`, body)
+
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"xyz","position":[3,3]},{"group_id":"xyz","position":[3,2]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+	xyzBooking := decodeData[manager.Booking](t, body)
+
+	status, body = doRequest(t, ctx, "POST", srv.URL+"/api/v1/rooms/main/seats/reservation", `{"seats_reservation":[{"group_id":"abc","position":[0,0]}]}`)
+	assert.Equal(t, http.StatusOK, status)
+	abcBooking := decodeData[manager.Booking](t, body)
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/reservations", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"code":0,"message":"Success","data":[`+
+		`{"group_id":"abc","position":[0,0],"booking_id":"`+abcBooking.ID+`"},`+
+		`{"group_id":"xyz","position":[3,2],"booking_id":"`+xyzBooking.ID+`"},`+
+		`{"group_id":"xyz","position":[3,3],"booking_id":"`+xyzBooking.ID+`"}]}`, body)
+
+	status, body = doRequest(t, ctx, "GET", srv.URL+"/api/v1/rooms/main/reservations?group_id=abc", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"code":0,"message":"Success","data":[{"group_id":"abc","position":[0,0],"booking_id":"`+abcBooking.ID+`"}]}`, body)
+}
+
+func doRequest(t *testing.T, ctx context.Context, method, url, body string) (int, string) {
+	t.Helper()
+
+	return doRequestWithHeader(t, ctx, nil, method, url, body)
+}
+
+func doRequestWithHeader(t *testing.T, ctx context.Context, header http.Header, method, url, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(ctx, method, url, strings.NewReader(body))
+	assert.NoError(t, err)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	buf, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	return resp.StatusCode, string(buf)
+}
+
+func decodeData[T any](t *testing.T, body string) T {
+	t.Helper()
+
+	var resp controller.SuccessResponse[T]
+	assert.NoError(t, json.Unmarshal([]byte(body), &resp))
+
+	return resp.Data
+}
+
+// testAdminToken guards the admin routes of the server started by
+// newTestServer.
+const testAdminToken = "admin-token"
+
+// adminHeader authorizes a request to the admin routes.
+var adminHeader = http.Header{"Authorization": {"Bearer " + testAdminToken}}
+
+// testRoom configures the server started by newTestServer. Its zero value
+// serves a single 4x4 room "main" with a min distance of 3 to groups abc
+// and xyz, and keeps events, history and audit entries in memory.
+type testRoom struct {
+	rooms    []config.Room
+	hold     config.Hold
+	groupIDs []string
+	eventBus *manager.EventBus
+	history  manager.EventHistory
+	audit    manager.AuditLog
+	// actor is sent as the actor of every request when set
+	actor string
+}
+
+type testServer struct {
+	URL   string
+	rooms manager.RoomRegistry
+}
+
+// newTestServer serves the handler of the server package, with its routes
+// and middlewares.
+func newTestServer(t *testing.T, room testRoom) testServer {
+	t.Helper()
+
+	logger := slog.Default()
+	if room.rooms == nil {
+		room.rooms = []config.Room{{ID: "main", NumRows: 4, NumCols: 4, MinDistance: 3}}
+	}
+	if room.groupIDs == nil {
+		room.groupIDs = []string{"abc", "xyz"}
+	}
+	if room.eventBus == nil {
+		room.eventBus = manager.NewEventBus(0)
+	}
+	if room.history == nil {
+		room.history = manager.NewMemoryEventHistory(logger, 0)
+	}
+	if room.audit == nil {
+		room.audit = manager.NewMemoryAuditLog()
+	}
+
+	groupManager := manager.NewGroupManager(room.groupIDs)
+	events := manager.EventPublishers{room.history, room.eventBus}
+	roomRegistry, err := manager.NewRoomRegistry(logger, room.rooms, &config.Store{}, &room.hold, groupManager, events, room.audit)
+	assert.NoError(t, err)
+
+	handler := server.NewServer(
+		logger,
+		testAdminToken,
+		middleware.NewIdempotencyCache(0),
+		controller.NewRoomController(logger, roomRegistry, groupManager),
+		controller.NewGroupController(logger, groupManager, roomRegistry),
+		controller.NewEventController(logger, roomRegistry, room.eventBus),
+		controller.NewWebSocketController(logger, roomRegistry, room.eventBus),
+		controller.NewAuditController(logger, room.audit),
+		controller.NewHistoryController(logger, roomRegistry, groupManager, room.history),
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if room.actor != "" {
+			r.Header.Set(middleware.ActorHeader, room.actor)
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	return testServer{URL: srv.URL, rooms: roomRegistry}
+}
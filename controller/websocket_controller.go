@@ -0,0 +1,288 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/namlh/vulcanLabsOA/consts/errcode"
+	"github.com/namlh/vulcanLabsOA/controller/request"
+	"github.com/namlh/vulcanLabsOA/manager"
+	"github.com/namlh/vulcanLabsOA/websocket"
+)
+
+const (
+	wsCommandSubscribe = "subscribe"
+	wsCommandReserve   = "reserve"
+	wsCommandCancel    = "cancel"
+
+	wsMessageReply        = "reply"
+	wsMessageAvailability = "availability"
+)
+
+// WebSocketController speaks a JSON protocol over WebSocket. Clients send
+// commands carrying an id that is echoed in the reply. After subscribing
+// to a room a client receives the available seats of every group, then
+// only the seats that became available or unavailable after each change.
+// The updates of a room are computed once and shared by its subscribers.
+type WebSocketController struct {
+	logger *slog.Logger
+	rooms  manager.RoomRegistry
+	events *manager.EventBus
+	mu     *sync.Mutex
+	feeds  map[string]*availabilityFeed
+}
+
+func NewWebSocketController(logger *slog.Logger, rooms manager.RoomRegistry, events *manager.EventBus) *WebSocketController {
+	return &WebSocketController{
+		logger: logger,
+		rooms:  rooms,
+		events: events,
+		mu:     new(sync.Mutex),
+		feeds:  make(map[string]*availabilityFeed),
+	}
+}
+
+type WSCommand struct {
+	ID        string               `json:"id"`
+	Type      string               `json:"type"`
+	RoomID    string               `json:"room_id"`
+	Seats     []request.GroupSeat  `json:"seats,omitempty"`
+	Positions []manager.Coordinate `json:"positions,omitempty"`
+}
+
+type WSReply struct {
+	Type  string       `json:"type"`
+	ID    string       `json:"id"`
+	OK    bool         `json:"ok"`
+	Data  any          `json:"data,omitempty"`
+	Error *ErrResponse `json:"error,omitempty"`
+}
+
+type WSAvailability struct {
+	Type    string `json:"type"`
+	RoomID  string `json:"room_id"`
+	Version uint64 `json:"version"`
+	// Full is set when Added holds every available seat rather than a diff.
+	Full    bool                            `json:"full,omitempty"`
+	Added   map[string][]manager.Coordinate `json:"added,omitempty"`
+	Removed map[string][]manager.Coordinate `json:"removed,omitempty"`
+}
+
+func (c *WebSocketController) Serve(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		c.logger.ErrorContext(r.Context(), "upgrade websocket", "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// the subscription is owned by a single goroutine that also pushes the
+	// availability updates
+	subscribeCh := make(chan WSCommand)
+	go func() {
+		if err := c.push(ctx, conn, subscribeCh); err != nil && ctx.Err() == nil {
+			c.logger.ErrorContext(ctx, "push availability", "error", err)
+		}
+		_ = conn.Close(websocket.CloseGoingAway, "")
+	}()
+
+	for {
+		opcode, msg, err := conn.ReadMessage()
+		if err != nil {
+			if closeErr := (websocket.CloseError{}); !errors.As(err, &closeErr) {
+				_ = conn.Close(websocket.CloseGoingAway, "")
+			}
+			return
+		}
+		if opcode != websocket.OpText {
+			_ = conn.Close(websocket.CloseInvalidPayload, "text messages only")
+			return
+		}
+
+		var cmd WSCommand
+		if err = json.Unmarshal(msg, &cmd); err != nil {
+			if err = c.reply(conn, cmd.ID, nil, AppError{
+				ErrCode:    errcode.InvalidParameters,
+				HttpStatus: http.StatusBadRequest,
+				Message:    "Invalid json syntax",
+				err:        err,
+			}); err != nil {
+				return
+			}
+			continue
+		}
+
+		if cmd.Type == wsCommandSubscribe {
+			select {
+			case subscribeCh <- cmd:
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		data, err := c.execute(ctx, cmd)
+		if err = c.reply(conn, cmd.ID, data, err); err != nil {
+			return
+		}
+	}
+}
+
+func (c *WebSocketController) execute(ctx context.Context, cmd WSCommand) (any, error) {
+	room, err := c.rooms.GetRoom(ctx, cmd.RoomID)
+	if err != nil {
+		return nil, roomAppError(err, cmd.RoomID)
+	}
+
+	switch cmd.Type {
+	case wsCommandReserve:
+		if err := validate(ctx, request.SeatsReservation{SeatsReservation: cmd.Seats}); err != nil {
+			return nil, err
+		}
+
+		booking, err := room.ReserveSeats(ctx, toSeats(cmd.Seats))
+		if err != nil {
+			return nil, seatAppError(err)
+		}
+
+		return booking, nil
+	case wsCommandCancel:
+		if err := room.CancelSeats(ctx, cmd.Positions); err != nil {
+			return nil, seatAppError(err)
+		}
+
+		return nil, nil
+	default:
+		return nil, AppError{
+			ErrCode:    errcode.InvalidParameters,
+			HttpStatus: http.StatusBadRequest,
+			err:        ValidationErrors{"type": fmt.Sprintf("unknown command type %q", cmd.Type)},
+		}
+	}
+}
+
+func (c *WebSocketController) reply(conn *websocket.Conn, id string, data any, err error) error {
+	reply := WSReply{Type: wsMessageReply, ID: id, OK: err == nil, Data: data}
+	if err != nil {
+		appErr := AppError{ErrCode: errcode.InternalError}
+		if !errors.As(err, &appErr) {
+			c.logger.Error("websocket command", "error", err)
+		}
+		resp := newErrResponse(appErr)
+		reply.Error = &resp
+	}
+
+	return writeJSON(conn, reply)
+}
+
+// push sends the availability of the subscribed room after each of its
+// changes until ctx is done.
+func (c *WebSocketController) push(ctx context.Context, conn *websocket.Conn, subscribeCh <-chan WSCommand) error {
+	var (
+		roomID  string
+		room    manager.RoomManager
+		feed    *availabilityFeed
+		updates chan []byte
+	)
+	defer func() {
+		if feed != nil {
+			c.leave(feed, updates)
+		}
+	}()
+
+	// resync joins the feed of the room, which sends every available seat
+	resync := func() error {
+		if feed != nil {
+			c.leave(feed, updates)
+			feed, updates = nil, nil
+		}
+
+		var err error
+		feed, updates, err = c.join(roomID, room)
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case cmd := <-subscribeCh:
+			newRoom, err := c.rooms.GetRoom(ctx, cmd.RoomID)
+			if err = c.reply(conn, cmd.ID, nil, roomAppErrorOrNil(err, cmd.RoomID)); err != nil {
+				return err
+			}
+			if newRoom == nil {
+				continue
+			}
+
+			roomID, room = cmd.RoomID, newRoom
+			if err = resync(); err != nil {
+				return err
+			}
+		case msg, ok := <-updates:
+			if !ok {
+				// fell behind the feed
+				if err := resync(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := conn.WriteMessage(websocket.OpText, msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func roomAppErrorOrNil(err error, roomID string) error {
+	if err == nil {
+		return nil
+	}
+
+	return roomAppError(err, roomID)
+}
+
+// diffSeats returns the seats of a that are not in b, per group. Both must
+// be sorted in row-major order.
+func diffSeats(a, b map[string][]manager.Coordinate) map[string][]manager.Coordinate {
+	diff := make(map[string][]manager.Coordinate)
+	for groupID, seats := range a {
+		other := b[groupID]
+		var missing []manager.Coordinate
+		for _, seat := range seats {
+			if _, found := slices.BinarySearchFunc(other, seat, compareCoordinate); !found {
+				missing = append(missing, seat)
+			}
+		}
+		if len(missing) > 0 {
+			diff[groupID] = missing
+		}
+	}
+
+	return diff
+}
+
+func compareCoordinate(a, b manager.Coordinate) int {
+	if a[0] != b[0] {
+		return a[0] - b[0]
+	}
+
+	return a[1] - b[1]
+}
+
+func writeJSON(conn *websocket.Conn, v any) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode message: %w", err)
+	}
+
+	return conn.WriteMessage(websocket.OpText, buf)
+}
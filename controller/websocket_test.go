@@ -0,0 +1,89 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/namlh/vulcanLabsOA/config"
+	"github.com/namlh/vulcanLabsOA/controller"
+	"github.com/namlh/vulcanLabsOA/manager"
+	"github.com/namlh/vulcanLabsOA/testing/assert"
+	"github.com/namlh/vulcanLabsOA/websocket"
+)
+
+func TestWebSocketController_Serve(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	srv := newTestServer(t, testRoom{
+		rooms:    []config.Room{{ID: "main", NumRows: 2, NumCols: 3, MinDistance: 2}},
+		eventBus: manager.NewEventBus(0),
+	})
+
+	conn, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/api/v1/ws")
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close(websocket.CloseNormal, "") })
+
+	send := func(msg string) {
+		t.Helper()
+		assert.NoError(t, conn.WriteMessage(websocket.OpText, []byte(msg)))
+	}
+	receive := func() string {
+		t.Helper()
+		_, msg, err := conn.ReadMessage()
+		assert.NoError(t, err)
+		return string(msg)
+	}
+	// replies and availability updates are pushed concurrently
+	receiveBoth := func() (string, string) {
+		t.Helper()
+		first, second := receive(), receive()
+		if strings.HasPrefix(first, `{"type":"reply"`) {
+			return first, second
+		}
+		return second, first
+	}
+
+	send(`{"id":"1","type":"subscribe","room_id":"foo"}`)
+	assert.Equal(t, `{"type":"reply","id":"1","ok":false,"error":{"code":2,"message":"room \"foo\" not found"}}`, receive())
+
+	send(`{"id":"2","type":"subscribe","room_id":"main"}`)
+	assert.Equal(t, `{"type":"reply","id":"2","ok":true}`, receive())
+	var availability controller.WSAvailability
+	assert.NoError(t, json.Unmarshal([]byte(receive()), &availability))
+	assert.Equal(t, true, availability.Full)
+	assert.Equal(t, 6, len(availability.Added["abc"]))
+	assert.Equal(t, 6, len(availability.Added["xyz"]))
+
+	// a second client of the room receives the same updates
+	other, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/api/v1/ws")
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = other.Close(websocket.CloseNormal, "") })
+	assert.NoError(t, other.WriteMessage(websocket.OpText, []byte(`{"id":"1","type":"subscribe","room_id":"main"}`)))
+	for range 2 {
+		_, _, err = other.ReadMessage()
+		assert.NoError(t, err)
+	}
+
+	send(`{"id":"3","type":"reserve","room_id":"main","seats":[{"group_id":"abc","position":[0,0]}]}`)
+	msg, update := receiveBoth()
+	var reply controller.WSReply
+	assert.NoError(t, json.Unmarshal([]byte(msg), &reply))
+	assert.Equal(t, "3", reply.ID)
+	assert.Equal(t, true, reply.OK)
+	assert.Equal(t, true, strings.Contains(update, `"removed":{"abc":[[0,0]],"xyz":[[0,0],[0,1],[1,0]]}`))
+	assert.Equal(t, false, strings.Contains(update, `"added"`))
+	_, otherUpdate, err := other.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, update, string(otherUpdate))
+
+	send(`{"id":"4","type":"reserve","room_id":"main","seats":[{"group_id":"xyz","position":[0,1]}]}`)
+	assert.Equal(t, `{"type":"reply","id":"4","ok":false,"error":{"code":1,"message":"Invalid parameters","errors":[{"index":0,"field":"position","code":"invalid_distance","message":"position [0,1] at index 0 violate min distance constraint"}]}}`, receive())
+
+	send(`{"id":"5","type":"cancel","room_id":"main","positions":[[0,0]]}`)
+	msg, update = receiveBoth()
+	assert.Equal(t, `{"type":"reply","id":"5","ok":true}`, msg)
+	assert.Equal(t, true, strings.Contains(update, `"added":{"abc":[[0,0]],"xyz":[[0,0],[0,1],[1,0]]}`))
+}
@@ -0,0 +1,133 @@
+package manager
+
+import (
+	"cmp"
+	"context"
+	"slices"
+)
+
+const (
+	ErrNotEnoughSeats = Error("not enough available seats")
+)
+
+// AllocateSeats picks count seats for groupID and reserves them in one
+// step, so the choice cannot be invalidated by a concurrent reservation.
+func (m *DefaultRoomManager) AllocateSeats(ctx context.Context, groupID string, count int) (booking Booking, err error) {
+	m.mu.Lock()
+	defer m.unlock()
+	defer m.reportVersion(ctx)
+
+	entry := AuditEntry{Operation: AuditOperationReserve}
+	defer func() {
+		entry.BookingID = booking.ID
+		m.record(ctx, entry, err)
+	}()
+
+	if err := m.checkVersion(ctx); err != nil {
+		return Booking{}, err
+	}
+	if !m.groupManager.HasGroupID(ctx, groupID) {
+		return Booking{}, ErrGroupIdNotFound
+	}
+
+	seats := m.allocation(groupID, count)
+	if seats == nil {
+		return Booking{}, ErrNotEnoughSeats
+	}
+	entry.Seats = seats
+
+	return m.book(ctx, seats)
+}
+
+// allocation picks count available seats for groupID, or returns nil when
+// there are not enough of them. The caller must hold m.mu.
+func (m *DefaultRoomManager) allocation(groupID string, count int) []Seat {
+	candidates := m.availableSeats(groupID)
+	if len(candidates) < count {
+		return nil
+	}
+
+	coords := m.pickSeats(candidates, count)
+	seats := make([]Seat, len(coords))
+	for i, coord := range coords {
+		seats[i] = Seat{GroupID: groupID, Coordinate: coord}
+	}
+
+	return seats
+}
+
+// pickSeats chooses count of the candidates, which must all be valid for
+// the same group, preferring seats next to each other:
+//  1. the first run of count consecutive seats in a row,
+//  2. otherwise the first cluster of adjacent seats that is large enough,
+//     taken in breadth-first order so the seats stay close to the seed,
+//  3. otherwise the largest clusters first.
+func (m *DefaultRoomManager) pickSeats(candidates []Coordinate, count int) []Coordinate {
+	if count <= 0 {
+		return nil
+	}
+
+	isCandidate := make(map[Coordinate]bool, len(candidates))
+	for _, coord := range candidates {
+		isCandidate[coord] = true
+	}
+
+	// consecutive seats in a row
+	for _, coord := range candidates {
+		if isCandidate[Coordinate{coord[0], coord[1] - 1}] {
+			continue
+		}
+
+		run := make([]Coordinate, 0, count)
+		for c := coord; isCandidate[c] && len(run) < count; c[1]++ {
+			run = append(run, c)
+		}
+		if len(run) == count {
+			return run
+		}
+	}
+
+	// clusters of adjacent seats
+	visited := make(map[Coordinate]bool, len(candidates))
+	var clusters [][]Coordinate
+	for _, coord := range candidates {
+		if visited[coord] {
+			continue
+		}
+
+		cluster := []Coordinate{coord}
+		visited[coord] = true
+		for i := 0; i < len(cluster); i++ {
+			cur := cluster[i]
+			neighbours := []Coordinate{
+				{cur[0], cur[1] - 1}, {cur[0], cur[1] + 1},
+				{cur[0] - 1, cur[1]}, {cur[0] + 1, cur[1]},
+			}
+			for _, next := range neighbours {
+				if isCandidate[next] && !visited[next] {
+					visited[next] = true
+					cluster = append(cluster, next)
+				}
+			}
+		}
+
+		if len(cluster) >= count {
+			return cluster[:count]
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	// no cluster is large enough, fill with the largest ones
+	slices.SortStableFunc(clusters, func(a, b []Coordinate) int {
+		return cmp.Compare(len(b), len(a))
+	})
+	picked := make([]Coordinate, 0, count)
+	for _, cluster := range clusters {
+		picked = append(picked, cluster[:min(len(cluster), count-len(picked))]...)
+		if len(picked) == count {
+			break
+		}
+	}
+
+	return picked
+}
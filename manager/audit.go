@@ -0,0 +1,251 @@
+package manager
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/namlh/vulcanLabsOA/config"
+	"github.com/namlh/vulcanLabsOA/consts/ctxkey"
+)
+
+const (
+	AuditOperationReserve     = "reserve"
+	AuditOperationCancel      = "cancel"
+	AuditOperationHold        = "hold"
+	AuditOperationMove        = "move"
+	AuditOperationTransaction = "transaction"
+	// AuditOperationReconfigure lists the reservations cancelled by a new
+	// room configuration.
+	AuditOperationReconfigure = "reconfigure"
+	// AuditOperationRelease lists the seats of a hold released because it
+	// expired, its group was deleted or they are no longer seats of the
+	// room. The actor of an expiry is AuditActorSweeper.
+	AuditOperationRelease = "release"
+
+	AuditActorSweeper = "sweeper"
+
+	AuditResultSuccess = "success"
+	// AuditResultPartial is a best-effort reservation that rejected some seats.
+	AuditResultPartial = "partial"
+	AuditResultFailure = "failure"
+)
+
+const (
+	defaultAuditQueryLimit = 50
+	maxAuditQueryLimit     = 500
+)
+
+// AuditEntry records one attempt to change the seats of a room. Seats of a
+// cancellation or a move carry the group they belonged to before the change.
+type AuditEntry struct {
+	ID        uint64     `json:"id"`
+	Time      time.Time  `json:"time"`
+	RequestID int64      `json:"request_id,omitempty"`
+	Actor     string     `json:"actor,omitempty"`
+	RoomID    string     `json:"room_id"`
+	Operation string     `json:"operation"`
+	Seats     []Seat     `json:"seats"`
+	Moves     []SeatMove `json:"moves,omitempty"`
+	BookingID string     `json:"booking_id,omitempty"`
+	HoldID    string     `json:"hold_id,omitempty"`
+	Result    string     `json:"result"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// AuditQuery filters audit entries. Zero fields do not filter. Entries are
+// returned in the order they were recorded, starting after the entry with
+// id After.
+type AuditQuery struct {
+	From      time.Time
+	To        time.Time
+	RoomID    string
+	GroupID   string
+	Operation string
+	Row       *int
+	Col       *int
+	After     uint64
+	Limit     int
+}
+
+func (q AuditQuery) match(entry AuditEntry) bool {
+	if !q.From.IsZero() && entry.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !entry.Time.Before(q.To) {
+		return false
+	}
+	if q.RoomID != "" && entry.RoomID != q.RoomID {
+		return false
+	}
+	if q.Operation != "" && entry.Operation != q.Operation {
+		return false
+	}
+	if q.GroupID != "" && !slices.ContainsFunc(entry.Seats, func(seat Seat) bool { return seat.GroupID == q.GroupID }) {
+		return false
+	}
+	if q.Row == nil && q.Col == nil {
+		return true
+	}
+
+	if slices.ContainsFunc(entry.Seats, func(seat Seat) bool { return q.matchCoordinate(seat.Coordinate) }) {
+		return true
+	}
+
+	return slices.ContainsFunc(entry.Moves, func(move SeatMove) bool { return q.matchCoordinate(move.To) })
+}
+
+func (q AuditQuery) matchCoordinate(coord Coordinate) bool {
+	return (q.Row == nil || coord[0] == *q.Row) && (q.Col == nil || coord[1] == *q.Col)
+}
+
+func (q AuditQuery) limit() int {
+	if q.Limit <= 0 {
+		return defaultAuditQueryLimit
+	}
+
+	return min(q.Limit, maxAuditQueryLimit)
+}
+
+// AuditLog is an append-only record of audit entries. Append assigns the id
+// and must only return once the entry survives a restart.
+// Entries are recorded after the change they describe is committed, so the
+// log is best-effort: an entry whose Append fails is only logged, and the
+// change stands.
+type AuditLog interface {
+	Append(ctx context.Context, entry AuditEntry) error
+	// Query returns the matching entries and whether more entries match
+	// after the last one returned.
+	Query(ctx context.Context, query AuditQuery) ([]AuditEntry, bool, error)
+	Close() error
+}
+
+func NewAuditLog(cfg *config.Audit) (AuditLog, error) {
+	if cfg.Dir == "" {
+		return NewMemoryAuditLog(), nil
+	}
+
+	return NewFileAuditLog(cfg.Dir)
+}
+
+type MemoryAuditLog struct {
+	mu      *sync.Mutex
+	entries auditEntries
+}
+
+func NewMemoryAuditLog() *MemoryAuditLog {
+	return &MemoryAuditLog{
+		mu: new(sync.Mutex),
+	}
+}
+
+func (l *MemoryAuditLog) Append(_ context.Context, entry AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, l.entries.next(entry))
+
+	return nil
+}
+
+func (l *MemoryAuditLog) Query(_ context.Context, query AuditQuery) ([]AuditEntry, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries, more := l.entries.query(query)
+
+	return entries, more, nil
+}
+
+func (l *MemoryAuditLog) Close() error {
+	return nil
+}
+
+// auditEntries is sorted by id.
+type auditEntries []AuditEntry
+
+// next assigns the id following the last entry.
+func (e auditEntries) next(entry AuditEntry) AuditEntry {
+	entry.ID = 1
+	if len(e) > 0 {
+		entry.ID = e[len(e)-1].ID + 1
+	}
+
+	return entry
+}
+
+func (e auditEntries) query(query AuditQuery) ([]AuditEntry, bool) {
+	limit := query.limit()
+
+	start, _ := slices.BinarySearchFunc(e, query.After+1, func(entry AuditEntry, id uint64) int {
+		switch {
+		case entry.ID < id:
+			return -1
+		case entry.ID > id:
+			return 1
+		}
+		return 0
+	})
+
+	var matched []AuditEntry
+	for _, entry := range e[start:] {
+		if !query.match(entry) {
+			continue
+		}
+		if len(matched) == limit {
+			return matched, true
+		}
+		matched = append(matched, entry)
+	}
+
+	return matched, false
+}
+
+// record queues an audit entry for an attempted change, which unlock
+// appends to the audit log. The change is already applied or rejected, so
+// a failure to record is only logged. The caller must hold m.mu.
+func (m *DefaultRoomManager) record(ctx context.Context, entry AuditEntry, err error) {
+	if m.audit == nil {
+		return
+	}
+
+	entry.Time = m.now()
+	entry.RoomID = m.cfg.ID
+	entry.RequestID, _ = ctx.Value(ctxkey.RequestID{}).(int64)
+	if entry.Actor == "" {
+		entry.Actor, _ = ctx.Value(ctxkey.Actor{}).(string)
+	}
+	if err != nil {
+		entry.Result = AuditResultFailure
+		entry.Error = err.Error()
+	} else if entry.Result == "" {
+		entry.Result = AuditResultSuccess
+	}
+
+	m.unrecorded = append(m.unrecorded, auditRecord{ctx: ctx, entry: entry})
+}
+
+// auditRecord is an audit entry queued by record with the context of the
+// operation it audits.
+type auditRecord struct {
+	ctx   context.Context
+	entry AuditEntry
+}
+
+// reservedSeats returns the seats at coordinates with the group currently
+// holding them, if any. The caller must hold m.mu.
+func (m *DefaultRoomManager) reservedSeats(coordinates []Coordinate) []Seat {
+	seats := make([]Seat, len(coordinates))
+	for i, coord := range coordinates {
+		seats[i] = Seat{Coordinate: coord}
+		if !m.inBound(coord) {
+			continue
+		}
+		if reservation, ok := m.reservedSeat[coord.AsIndex(m.cfg.NumCols)]; ok {
+			seats[i].GroupID = reservation.GroupID
+		}
+	}
+
+	return seats
+}
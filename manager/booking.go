@@ -0,0 +1,252 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/namlh/vulcanLabsOA/util/idutil"
+)
+
+const (
+	ErrBookingNotFound = Error("booking not found")
+)
+
+// Booking groups the seats reserved by a single reservation. A booking
+// disappears once all of its seats are cancelled.
+type Booking struct {
+	ID    string `json:"booking_id"`
+	Seats []Seat `json:"seats"`
+}
+
+type Reservation struct {
+	Seat
+	BookingID string `json:"booking_id,omitempty"`
+}
+
+func (m *DefaultRoomManager) GetBooking(_ context.Context, bookingID string) (Booking, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	booking, ok := m.bookings[bookingID]
+	if !ok {
+		return Booking{}, ErrBookingNotFound
+	}
+
+	return Booking{
+		ID:    booking.ID,
+		Seats: slices.Clone(booking.Seats),
+	}, nil
+}
+
+// CancelBooking releases the given seats of a booking, or all of them
+// when coordinates is empty.
+func (m *DefaultRoomManager) CancelBooking(ctx context.Context, bookingID string, coordinates []Coordinate) (err error) {
+	m.mu.Lock()
+	defer m.unlock()
+	defer m.reportVersion(ctx)
+
+	defer m.fillWaitlistOnSuccess(ctx, &err)
+	entry := AuditEntry{Operation: AuditOperationCancel, Seats: m.reservedSeats(coordinates), BookingID: bookingID}
+	defer func() {
+		m.record(ctx, entry, err)
+	}()
+
+	if err := m.checkVersion(ctx); err != nil {
+		return err
+	}
+
+	booking, ok := m.bookings[bookingID]
+	if !ok {
+		return ErrBookingNotFound
+	}
+
+	if len(coordinates) == 0 {
+		coordinates = make([]Coordinate, len(booking.Seats))
+		for i, seat := range booking.Seats {
+			coordinates[i] = seat.Coordinate
+		}
+		entry.Seats = m.reservedSeats(coordinates)
+	}
+
+	var errs SeatErrors
+	idxSet := make(map[int64]struct{})
+	for i, coord := range coordinates {
+		seat := Seat{Coordinate: coord}
+		if !m.inBound(coord) {
+			errs = append(errs, SeatError{seat, SeatErrorCodeOutOfBound, i})
+			continue
+		}
+
+		idx := coord.AsIndex(m.cfg.NumCols)
+		if _, ok := idxSet[idx]; ok {
+			errs = append(errs, SeatError{seat, SeatErrorCodeDuplicatedPosition, i})
+			continue
+		}
+		idxSet[idx] = struct{}{}
+
+		if reservation, ok := m.reservedSeat[idx]; !ok || reservation.BookingID != bookingID {
+			errs = append(errs, SeatError{seat, SeatErrorCodeNotInBooking, i})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return m.commit(ctx, Mutation{Cancelled: coordinates})
+}
+
+// book reserves already validated seats under a new booking.
+// The caller must hold m.mu.
+func (m *DefaultRoomManager) book(ctx context.Context, seats []Seat) (Booking, error) {
+	booking, mutation := newBooking(seats)
+	if err := m.commit(ctx, mutation); err != nil {
+		return Booking{}, err
+	}
+
+	return booking, nil
+}
+
+func newBooking(seats []Seat) (Booking, Mutation) {
+	booking := Booking{
+		ID:    idutil.New(),
+		Seats: seats,
+	}
+
+	reservations := make([]Reservation, len(seats))
+	for i, seat := range seats {
+		reservations[i] = Reservation{Seat: seat, BookingID: booking.ID}
+	}
+
+	return booking, Mutation{Reserved: reservations}
+}
+
+// commit durably records the mutation before applying it in memory. A
+// mutation that changes no seat is neither recorded nor applied, so the
+// version of the room stays the same. The caller must hold m.mu.
+func (m *DefaultRoomManager) commit(ctx context.Context, mutation Mutation) error {
+	if len(mutation.Reserved) == 0 && len(mutation.Cancelled) == 0 {
+		return nil
+	}
+	if err := m.store.Append(ctx, mutation); err != nil {
+		return fmt.Errorf("append mutation: %w", err)
+	}
+	m.applyCommitted(mutation)
+
+	return nil
+}
+
+// applyCommitted applies a mutation already recorded in the store and
+// publishes it, unless it changes no seat. The caller must hold m.mu.
+func (m *DefaultRoomManager) applyCommitted(mutation Mutation) {
+	cancelled := make([]Reservation, 0, len(mutation.Cancelled))
+	for _, coord := range mutation.Cancelled {
+		if reservation, ok := m.reservedSeat[coord.AsIndex(m.cfg.NumCols)]; ok {
+			cancelled = append(cancelled, reservation)
+		}
+	}
+
+	m.apply(mutation)
+	if len(mutation.Reserved) == 0 && len(cancelled) == 0 {
+		return
+	}
+
+	event := Event{Reserved: mutation.Reserved, Cancelled: cancelled}
+	switch {
+	case len(event.Reserved) > 0 && len(event.Cancelled) > 0:
+		event.Type = EventChange
+	case len(event.Reserved) > 0:
+		event.Type = EventReservation
+	default:
+		event.Type = EventCancellation
+	}
+	m.publish(event)
+}
+
+// publish stamps event with the current version of the room and queues it
+// for the event publisher until unlock. The caller must hold m.mu.
+func (m *DefaultRoomManager) publish(event Event) {
+	if m.events == nil {
+		return
+	}
+
+	event.RoomID = m.cfg.ID
+	event.Version = m.version
+	event.Time = m.now()
+	m.unpublished = append(m.unpublished, event)
+}
+
+// unlock releases m.mu, then publishes the queued events and records the
+// queued audit entries, so that the history, the audit log and the outbox
+// are written without holding up the room. The mutations themselves are
+// durable in the store before they are applied.
+func (m *DefaultRoomManager) unlock() {
+	queued := len(m.unpublished) > 0 || len(m.unrecorded) > 0
+	m.mu.Unlock()
+
+	if queued {
+		m.flush()
+	}
+}
+
+// flush writes the queued events and audit entries in the order they were
+// queued. A flush may write those queued by another mutation, which then
+// waits on m.flushMu until they are written.
+func (m *DefaultRoomManager) flush() {
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+
+	m.mu.Lock()
+	events, records := m.unpublished, m.unrecorded
+	m.unpublished, m.unrecorded = nil, nil
+	m.mu.Unlock()
+
+	for _, event := range events {
+		m.events.Publish(event)
+	}
+	for _, record := range records {
+		if err := m.audit.Append(record.ctx, record.entry); err != nil {
+			m.logger.ErrorContext(record.ctx, "append audit entry", "operation", record.entry.Operation, "error", err)
+		}
+	}
+}
+
+// apply updates reserved seats, bookings and the seat index. Cancellations are applied
+// before reservations. A new room configuration is applied by reconfigure.
+// The caller must hold m.mu.
+func (m *DefaultRoomManager) apply(mutation Mutation) {
+	for _, coord := range mutation.Cancelled {
+		idx := coord.AsIndex(m.cfg.NumCols)
+		reservation, ok := m.reservedSeat[idx]
+		if !ok {
+			continue
+		}
+		delete(m.reservedSeat, idx)
+		m.index.remove(coord, reservation.GroupID)
+
+		booking, ok := m.bookings[reservation.BookingID]
+		if !ok {
+			continue
+		}
+		booking.Seats = slices.DeleteFunc(booking.Seats, func(seat Seat) bool {
+			return seat.Coordinate == coord
+		})
+		if len(booking.Seats) == 0 {
+			delete(m.bookings, booking.ID)
+		}
+	}
+
+	for _, reservation := range mutation.Reserved {
+		m.reservedSeat[reservation.AsIndex(m.cfg.NumCols)] = reservation
+		m.index.add(reservation.Coordinate, reservation.GroupID)
+
+		booking, ok := m.bookings[reservation.BookingID]
+		if !ok {
+			booking = &Booking{ID: reservation.BookingID}
+			m.bookings[booking.ID] = booking
+		}
+		booking.Seats = append(booking.Seats, reservation.Seat)
+	}
+
+	m.version++
+}
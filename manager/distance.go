@@ -0,0 +1,99 @@
+package manager
+
+import (
+	"fmt"
+
+	"github.com/namlh/vulcanLabsOA/util/mathutil"
+)
+
+const (
+	DistanceMetricManhattan = "manhattan"
+	DistanceMetricChebyshev = "chebyshev"
+	DistanceMetricEuclidean = "euclidean"
+	DistanceMetricRow       = "row"
+)
+
+// DistanceMetric decides how far apart two seats are for the separation rule.
+type DistanceMetric interface {
+	Name() string
+	// Closer reports whether a and b are closer than minDistance.
+	Closer(a, b Coordinate, minDistance int) bool
+	// Reach returns how many rows and columns away from a seat another
+	// seat can be while still being closer than minDistance.
+	Reach(minDistance int) (rows, cols int)
+}
+
+func NewDistanceMetric(name string) (DistanceMetric, error) {
+	switch name {
+	case "", DistanceMetricManhattan:
+		return ManhattanDistance{}, nil
+	case DistanceMetricChebyshev:
+		return ChebyshevDistance{}, nil
+	case DistanceMetricEuclidean:
+		return EuclideanDistance{}, nil
+	case DistanceMetricRow:
+		return RowDistance{}, nil
+	default:
+		return nil, fmt.Errorf("unknown distance metric %q", name)
+	}
+}
+
+type ManhattanDistance struct{}
+
+func (ManhattanDistance) Name() string {
+	return DistanceMetricManhattan
+}
+
+func (ManhattanDistance) Closer(a, b Coordinate, minDistance int) bool {
+	return mathutil.AbsDiff(a[0], b[0])+mathutil.AbsDiff(a[1], b[1]) < minDistance
+}
+
+func (ManhattanDistance) Reach(minDistance int) (int, int) {
+	return minDistance - 1, minDistance - 1
+}
+
+// ChebyshevDistance also counts diagonal neighbours as being 1 apart.
+type ChebyshevDistance struct{}
+
+func (ChebyshevDistance) Name() string {
+	return DistanceMetricChebyshev
+}
+
+func (ChebyshevDistance) Closer(a, b Coordinate, minDistance int) bool {
+	return max(mathutil.AbsDiff(a[0], b[0]), mathutil.AbsDiff(a[1], b[1])) < minDistance
+}
+
+func (ChebyshevDistance) Reach(minDistance int) (int, int) {
+	return minDistance - 1, minDistance - 1
+}
+
+type EuclideanDistance struct{}
+
+func (EuclideanDistance) Name() string {
+	return DistanceMetricEuclidean
+}
+
+func (EuclideanDistance) Closer(a, b Coordinate, minDistance int) bool {
+	dr, dc := a[0]-b[0], a[1]-b[1]
+	return dr*dr+dc*dc < minDistance*minDistance
+}
+
+func (EuclideanDistance) Reach(minDistance int) (int, int) {
+	return minDistance - 1, minDistance - 1
+}
+
+// RowDistance only separates seats of the same row, seats in different
+// rows are never too close.
+type RowDistance struct{}
+
+func (RowDistance) Name() string {
+	return DistanceMetricRow
+}
+
+func (RowDistance) Closer(a, b Coordinate, minDistance int) bool {
+	return a[0] == b[0] && mathutil.AbsDiff(a[1], b[1]) < minDistance
+}
+
+func (RowDistance) Reach(minDistance int) (int, int) {
+	return 0, minDistance - 1
+}
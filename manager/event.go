@@ -0,0 +1,179 @@
+package manager
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/namlh/vulcanLabsOA/config"
+)
+
+const (
+	EventReservation  = "reservation"
+	EventCancellation = "cancellation"
+	// EventChange is a single change that both cancels and reserves seats,
+	// such as a move or a transaction.
+	EventChange      = "change"
+	EventHold        = "hold"
+	EventHoldRelease = "hold_release"
+	// EventRestore carries every reserved seat of a room loaded from its
+	// store, which replaces the previous state of the room.
+	EventRestore = "restore"
+	// EventWaitlistFulfilled follows the reservation made for a waitlist
+	// entry.
+	EventWaitlistFulfilled = "waitlist_fulfilled"
+	// EventWaitlistDropped carries a waitlist entry that a reconfiguration
+	// of its room made impossible to satisfy.
+	EventWaitlistDropped = "waitlist_dropped"
+	// EventReconfiguration carries the new configuration of a room.
+	EventReconfiguration = "reconfiguration"
+	// EventCheckpoint is only found in the event history, where it replaces
+	// the oldest events of a room with the state of the room after them.
+	EventCheckpoint = "checkpoint"
+)
+
+const (
+	defaultEventHistorySize = 1000
+	subscriptionBufferSize  = 64
+)
+
+// Event describes a committed change of a room. Version is the version of
+// the room right after the change, so consecutive events of a room have
+// consecutive versions.
+type Event struct {
+	Type      string         `json:"type"`
+	RoomID    string         `json:"room_id"`
+	Version   uint64         `json:"version"`
+	Time      time.Time      `json:"time"`
+	Reserved  []Reservation  `json:"reserved,omitempty"`
+	Cancelled []Reservation  `json:"cancelled,omitempty"`
+	Hold      *Hold          `json:"hold,omitempty"`
+	Waitlist  *WaitlistEntry `json:"waitlist,omitempty"`
+	// Room is the configuration of the room after a restore, a
+	// reconfiguration or a checkpoint.
+	Room *config.Room `json:"room,omitempty"`
+	// Holds are the holds of the room at a checkpoint.
+	Holds []*Hold `json:"holds,omitempty"`
+}
+
+type EventPublisher interface {
+	Publish(event Event)
+}
+
+// EventPublishers publishes every event to each of its publishers in order.
+type EventPublishers []EventPublisher
+
+func (p EventPublishers) Publish(event Event) {
+	for _, publisher := range p {
+		publisher.Publish(event)
+	}
+}
+
+// EventBus fans events out to subscribers and keeps the latest events of
+// each room so that subscribers can resume after a disconnection.
+type EventBus struct {
+	mu            *sync.Mutex
+	historySize   int
+	history       map[string][]Event
+	subscriptions map[*Subscription]struct{}
+}
+
+func NewEventBus(historySize int) *EventBus {
+	if historySize <= 0 {
+		historySize = defaultEventHistorySize
+	}
+
+	return &EventBus{
+		mu:            new(sync.Mutex),
+		historySize:   historySize,
+		history:       make(map[string][]Event),
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives the events of one room, or of every room when its
+// room id is empty. C is closed when the subscriber falls too far behind.
+type Subscription struct {
+	roomID string
+	c      chan Event
+}
+
+func (s *Subscription) C() <-chan Event {
+	return s.c
+}
+
+// Publish never blocks: subscribers that cannot keep up are dropped.
+func (b *EventBus) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	history := b.history[event.RoomID]
+	if len(history) == b.historySize {
+		copy(history, history[1:])
+		history = history[:len(history)-1]
+	}
+	b.history[event.RoomID] = append(history, event)
+
+	for sub := range b.subscriptions {
+		if sub.roomID != "" && sub.roomID != event.RoomID {
+			continue
+		}
+
+		select {
+		case sub.c <- event:
+		default:
+			delete(b.subscriptions, sub)
+			close(sub.c)
+		}
+	}
+}
+
+func (b *EventBus) Subscribe(roomID string) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.subscribe(roomID)
+}
+
+// SubscribeAfter subscribes to roomID and returns the retained events
+// after version. ok is false when events after version are no longer
+// retained, in which case the subscriber should reload the room.
+func (b *EventBus) SubscribeAfter(roomID string, version uint64) (sub *Subscription, events []Event, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	history := b.history[roomID]
+	i, _ := slices.BinarySearchFunc(history, version+1, func(event Event, version uint64) int {
+		switch {
+		case event.Version < version:
+			return -1
+		case event.Version > version:
+			return 1
+		}
+		return 0
+	})
+	events = slices.Clone(history[i:])
+	ok = len(history) > 0 && history[0].Version <= version+1 && version <= history[len(history)-1].Version
+
+	return b.subscribe(roomID), events, ok
+}
+
+func (b *EventBus) subscribe(roomID string) *Subscription {
+	sub := &Subscription{
+		roomID: roomID,
+		c:      make(chan Event, subscriptionBufferSize),
+	}
+	b.subscriptions[sub] = struct{}{}
+
+	return sub
+}
+
+func (b *EventBus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscriptions[sub]; ok {
+		delete(b.subscriptions, sub)
+		close(sub.c)
+	}
+}
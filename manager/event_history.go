@@ -0,0 +1,360 @@
+package manager
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/namlh/vulcanLabsOA/config"
+	"github.com/namlh/vulcanLabsOA/util/jsonlog"
+)
+
+const defaultMaxHistoryEvents = 10000
+
+// EventHistory keeps the latest events of every room so that past states
+// of a room can be rebuilt. Once a room has more than its maximum number of
+// events, the oldest half of them is folded into a checkpoint event.
+type EventHistory interface {
+	EventPublisher
+	// Events returns the events of roomID in the order they were published.
+	Events(ctx context.Context, roomID string) ([]Event, error)
+	Close() error
+}
+
+func NewEventHistory(logger *slog.Logger, cfg *config.History) (EventHistory, error) {
+	if cfg.Dir == "" {
+		return NewMemoryEventHistory(logger, cfg.MaxEvents), nil
+	}
+
+	return NewFileEventHistory(logger, cfg.Dir, cfg.MaxEvents)
+}
+
+type MemoryEventHistory struct {
+	mu        *sync.Mutex
+	logger    *slog.Logger
+	maxEvents int
+	events    map[string][]Event
+}
+
+func NewMemoryEventHistory(logger *slog.Logger, maxEvents int) *MemoryEventHistory {
+	if maxEvents <= 0 {
+		maxEvents = defaultMaxHistoryEvents
+	}
+
+	return &MemoryEventHistory{
+		mu:        new(sync.Mutex),
+		logger:    logger,
+		maxEvents: maxEvents,
+		events:    make(map[string][]Event),
+	}
+}
+
+func (h *MemoryEventHistory) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.events[event.RoomID] = append(h.events[event.RoomID], event)
+	h.compact(event.RoomID)
+}
+
+// compact folds the oldest events of roomID into a checkpoint when there
+// are too many of them, and reports whether it did. The caller must hold
+// h.mu.
+func (h *MemoryEventHistory) compact(roomID string) bool {
+	events := h.events[roomID]
+	if len(events) <= h.maxEvents {
+		return false
+	}
+
+	keep := h.maxEvents / 2
+	folded := events[:len(events)-keep]
+	compacted := make([]Event, 0, keep+1)
+
+	// events before the first baseline have no configuration to be
+	// replayed with
+	if start := slices.IndexFunc(folded, isBaseline); start >= 0 {
+		compacted = append(compacted, checkpoint(h.logger, folded[start:]))
+	} else {
+		h.logger.Warn("drop events without a baseline from history", "room_id", roomID, "count", len(folded))
+	}
+	h.events[roomID] = append(compacted, events[len(events)-keep:]...)
+
+	return true
+}
+
+func (h *MemoryEventHistory) Events(_ context.Context, roomID string) ([]Event, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return slices.Clone(h.events[roomID]), nil
+}
+
+func (h *MemoryEventHistory) Close() error {
+	return nil
+}
+
+// FileEventHistory appends every event to a log file, which is rewritten
+// when the events of a room are compacted. The events are kept in memory to
+// be replayed.
+type FileEventHistory struct {
+	*MemoryEventHistory
+	log *jsonlog.Log
+}
+
+func NewFileEventHistory(logger *slog.Logger, dir string, maxEvents int) (*FileEventHistory, error) {
+	h := &FileEventHistory{
+		MemoryEventHistory: NewMemoryEventHistory(logger, maxEvents),
+	}
+
+	log, err := jsonlog.Open(filepath.Join(dir, "events.log"), func(line []byte) error {
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			return err
+		}
+		h.events[event.RoomID] = append(h.events[event.RoomID], event)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("open event history: %w", err)
+	}
+	h.log = log
+
+	compacted := false
+	for roomID := range h.events {
+		compacted = h.compact(roomID) || compacted
+	}
+	if compacted {
+		if err = h.rewrite(); err != nil {
+			return nil, errors.Join(fmt.Errorf("rewrite event history: %w", err), log.Close())
+		}
+	}
+
+	return h, nil
+}
+
+// Publish records event in memory even when writing it fails, so that the
+// history stays consistent until the next restart.
+func (h *FileEventHistory) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.log.Append(event); err != nil {
+		h.logger.Error("append event history", "room_id", event.RoomID, "version", event.Version, "error", err)
+	}
+	h.events[event.RoomID] = append(h.events[event.RoomID], event)
+
+	// the log still holds every event when rewriting it fails, which
+	// is retried on the next compaction
+	if h.compact(event.RoomID) {
+		if err := h.rewrite(); err != nil {
+			h.logger.Error("rewrite event history", "error", err)
+		}
+	}
+}
+
+// rewrite replaces the log with the events kept in memory, room by room.
+// The caller must hold h.mu.
+func (h *FileEventHistory) rewrite() error {
+	roomIDs := slices.Sorted(maps.Keys(h.events))
+	values := make([]any, 0)
+	for _, roomID := range roomIDs {
+		for _, event := range h.events[roomID] {
+			values = append(values, event)
+		}
+	}
+
+	return h.log.Rewrite(values)
+}
+
+func (h *FileEventHistory) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.log.Close()
+}
+
+// RoomState is a room as of a point of its history. Version and Time are
+// those of the last event before the point.
+type RoomState struct {
+	RoomID    string                  `json:"room_id"`
+	Room      config.Room             `json:"room"`
+	Version   uint64                  `json:"version"`
+	Time      time.Time               `json:"time"`
+	Reserved  []Reservation           `json:"reserved"`
+	Held      []Seat                  `json:"held"`
+	Available map[string][]Coordinate `json:"available"`
+}
+
+// HistoryPoint selects the events published at or before Time, or at or
+// before Version when Time is zero.
+type HistoryPoint struct {
+	Time    time.Time
+	Version uint64
+}
+
+func (p HistoryPoint) includes(event Event) bool {
+	if !p.Time.IsZero() {
+		return !event.Time.After(p.Time)
+	}
+
+	return event.Version <= p.Version
+}
+
+// isBaseline reports whether event sets the whole state of its room, so
+// that a replay can start from it.
+func isBaseline(event Event) bool {
+	return (event.Type == EventRestore || event.Type == EventCheckpoint) && event.Room != nil
+}
+
+// checkpoint folds events, which start with a baseline, into a single
+// event that carries the state of the room after them.
+func checkpoint(logger *slog.Logger, events []Event) Event {
+	m := replayRoom(logger, *events[0].Room, NewGroupManager(nil), events)
+	last := events[len(events)-1]
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room := *m.cfg
+	event := Event{
+		Type:     EventCheckpoint,
+		RoomID:   last.RoomID,
+		Version:  last.Version,
+		Time:     last.Time,
+		Reserved: make([]Reservation, 0, len(m.reservedSeat)),
+		Room:     &room,
+		Holds:    make([]*Hold, 0, len(m.holds)),
+	}
+	for _, reservation := range m.reservedSeat {
+		event.Reserved = append(event.Reserved, reservation)
+	}
+	slices.SortFunc(event.Reserved, func(a, b Reservation) int {
+		return cmp.Or(cmp.Compare(a.Row(), b.Row()), cmp.Compare(a.Col(), b.Col()))
+	})
+	for _, hold := range m.holds {
+		event.Holds = append(event.Holds, hold.clone())
+	}
+	slices.SortFunc(event.Holds, func(a, b *Hold) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return event
+}
+
+// replayRoom applies events in order to a room that starts with cfg. The
+// events before the last baseline are skipped, as it replaces the room.
+func replayRoom(logger *slog.Logger, cfg config.Room, groupManager GroupManager, events []Event) *DefaultRoomManager {
+	for i := len(events) - 1; i >= 0; i-- {
+		if isBaseline(events[i]) {
+			events = events[i:]
+			break
+		}
+	}
+
+	newRoom := func(cfg config.Room) *DefaultRoomManager {
+		return newRoomManager(logger, &cfg, &config.Hold{}, groupManager, NewMemoryRoomStore(), nil, nil)
+	}
+
+	m := newRoom(cfg)
+	for _, event := range events {
+		if event.Type == EventRestore || event.Type == EventCheckpoint {
+			if event.Room != nil {
+				cfg = *event.Room
+			}
+			m = newRoom(cfg)
+		}
+
+		m.mu.Lock()
+		switch event.Type {
+		case EventRestore, EventCheckpoint, EventReservation, EventCancellation, EventChange:
+			cancelled := make([]Coordinate, len(event.Cancelled))
+			for i, reservation := range event.Cancelled {
+				cancelled[i] = reservation.Coordinate
+			}
+			m.apply(Mutation{Reserved: event.Reserved, Cancelled: cancelled})
+			for _, hold := range event.Holds {
+				m.addHold(hold.clone())
+			}
+		case EventHold:
+			m.addHold(event.Hold.clone())
+		case EventHoldRelease:
+			if hold, ok := m.holds[event.Hold.ID]; ok {
+				m.releaseHold(hold)
+			}
+		case EventReconfiguration:
+			room := *event.Room
+			m.reconfigure(&room)
+		}
+		m.mu.Unlock()
+	}
+
+	return m
+}
+
+// HistoryNotRetainedError reports a point older than the oldest state of a
+// room that its history still holds.
+type HistoryNotRetainedError struct {
+	// Earliest is the oldest point that can be rebuilt, nil when there is
+	// none.
+	Earliest *HistoryPoint
+}
+
+func (e HistoryNotRetainedError) Error() string {
+	if e.Earliest == nil {
+		return "history not retained"
+	}
+
+	return fmt.Sprintf("history not retained before version %d at %s", e.Earliest.Version, e.Earliest.Time.Format(time.RFC3339Nano))
+}
+
+// ReplayRoomState rebuilds the state of a room by replaying its events up
+// to point, from the last restore or checkpoint before it. The room starts
+// with the configuration recorded by that event, changed by the
+// reconfiguration events after it. Availability is computed with the
+// current groups. It fails with HistoryNotRetainedError when no restore or
+// checkpoint precedes point.
+func ReplayRoomState(ctx context.Context, logger *slog.Logger, cfg config.Room, groupManager GroupManager, events []Event, point HistoryPoint) (RoomState, error) {
+	n := slices.IndexFunc(events, func(event Event) bool { return !point.includes(event) })
+	if n < 0 {
+		n = len(events)
+	}
+	if !slices.ContainsFunc(events[:n], isBaseline) {
+		err := HistoryNotRetainedError{}
+		if i := slices.IndexFunc(events, isBaseline); i >= 0 {
+			err.Earliest = &HistoryPoint{Time: events[i].Time, Version: events[i].Version}
+		}
+		return RoomState{}, err
+	}
+	events = events[:n]
+
+	m := replayRoom(logger, cfg, groupManager, events)
+	state := RoomState{
+		RoomID:  cfg.ID,
+		Version: events[n-1].Version,
+		Time:    events[n-1].Time,
+	}
+
+	state.Room = m.Config(ctx)
+	state.Reserved = m.ListReservations(ctx, "")
+	state.Available, _, _ = m.ListAvailableSeats(ctx, "")
+
+	m.mu.Lock()
+	state.Held = make([]Seat, 0, len(m.heldSeat))
+	for idx, seat := range m.heldSeat {
+		state.Held = append(state.Held, Seat{GroupID: seat.GroupID, Coordinate: m.indexToCoordinate(idx)})
+	}
+	m.mu.Unlock()
+	slices.SortFunc(state.Held, func(a, b Seat) int {
+		return cmp.Or(cmp.Compare(a.Row(), b.Row()), cmp.Compare(a.Col(), b.Col()))
+	})
+
+	return state, nil
+}
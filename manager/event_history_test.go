@@ -0,0 +1,82 @@
+package manager_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/namlh/vulcanLabsOA/config"
+	"github.com/namlh/vulcanLabsOA/manager"
+	"github.com/namlh/vulcanLabsOA/testing/assert"
+)
+
+func TestFileEventHistory_Compact(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	logger := slog.Default()
+	dir := t.TempDir()
+	cfg := config.Room{ID: "main", NumRows: 4, NumCols: 4, MinDistance: 1}
+	groupManager := manager.NewGroupManager([]string{"abc", "xyz"})
+
+	full := manager.NewMemoryEventHistory(logger, 0)
+	history, err := manager.NewFileEventHistory(logger, dir, 6)
+	assert.NoError(t, err)
+	room := manager.NewRoomManager(logger, &cfg, &config.Hold{}, groupManager, manager.NewMemoryRoomStore(), manager.EventPublishers{full, history}, nil)
+	assert.NoError(t, room.Restore(ctx))
+
+	_, err = room.ReserveSeats(ctx, []manager.Seat{{GroupID: "abc", Coordinate: manager.Coordinate{0, 0}}})
+	assert.NoError(t, err)
+	_, err = room.HoldSeats(ctx, []manager.Seat{{GroupID: "xyz", Coordinate: manager.Coordinate{3, 3}}})
+	assert.NoError(t, err)
+	_, err = room.Reconfigure(ctx, config.Room{NumRows: 5, NumCols: 4, MinDistance: 1}, false)
+	assert.NoError(t, err)
+	for col := range 4 {
+		_, err = room.ReserveSeats(ctx, []manager.Seat{{GroupID: "abc", Coordinate: manager.Coordinate{4, col}}})
+		assert.NoError(t, err)
+		assert.NoError(t, room.CancelSeats(ctx, []manager.Coordinate{{4, col}}))
+	}
+	_, err = room.ReserveSeats(ctx, []manager.Seat{{GroupID: "xyz", Coordinate: manager.Coordinate{2, 0}}})
+	assert.NoError(t, err)
+
+	fullEvents, err := full.Events(ctx, cfg.ID)
+	assert.NoError(t, err)
+	events, err := history.Events(ctx, cfg.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, true, len(events) <= 6)
+	assert.Equal(t, manager.EventCheckpoint, events[0].Type)
+	assert.NoError(t, history.Close())
+
+	// the compacted history is read back from its file
+	history, err = manager.NewFileEventHistory(logger, dir, 6)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = history.Close() })
+	reopened, err := history.Events(ctx, cfg.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, len(events), len(reopened))
+
+	// points that were not folded are rebuilt as with the full history
+	for _, event := range reopened {
+		point := manager.HistoryPoint{Version: event.Version}
+		want, err := manager.ReplayRoomState(ctx, logger, cfg, groupManager, fullEvents, point)
+		assert.NoError(t, err)
+		got, err := manager.ReplayRoomState(ctx, logger, cfg, groupManager, reopened, point)
+		assert.NoError(t, err)
+		assert.Equal(t, want.Version, got.Version)
+		assert.Equal(t, want.Room.NumRows, got.Room.NumRows)
+		assert.Equal(t, len(want.Reserved), len(got.Reserved))
+		for i := range want.Reserved {
+			assert.Equal(t, want.Reserved[i], got.Reserved[i])
+		}
+		assert.Equal(t, len(want.Held), len(got.Held))
+		for groupID, seats := range want.Available {
+			assert.Equal(t, len(seats), len(got.Available[groupID]))
+		}
+	}
+
+	// points that were folded cannot be rebuilt anymore
+	_, err = manager.ReplayRoomState(ctx, logger, cfg, groupManager, reopened, manager.HistoryPoint{Version: reopened[0].Version - 1})
+	nErr, ok := err.(manager.HistoryNotRetainedError)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, reopened[0].Version, nErr.Earliest.Version)
+}
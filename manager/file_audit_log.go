@@ -0,0 +1,105 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/namlh/vulcanLabsOA/util/jsonlog"
+)
+
+// FileAuditLog appends every entry as a line of a log file that is never
+// rewritten. Only the offsets of the entries are kept in memory, queries
+// read the entries back from the file.
+type FileAuditLog struct {
+	mu  *sync.Mutex
+	log *jsonlog.Log
+	// offsets holds the offset of every entry. Ids are consecutive from 1,
+	// so the entry with id n starts at offsets[n-1].
+	offsets []int64
+}
+
+func NewFileAuditLog(dir string) (*FileAuditLog, error) {
+	l := &FileAuditLog{
+		mu: new(sync.Mutex),
+	}
+
+	log, err := jsonlog.OpenOffsets(filepath.Join(dir, "audit.log"), func(offset int64, line []byte) error {
+		var entry struct {
+			ID uint64 `json:"id"`
+		}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return err
+		}
+		if entry.ID != uint64(len(l.offsets))+1 {
+			return fmt.Errorf("unexpected id %d", entry.ID)
+		}
+		l.offsets = append(l.offsets, offset)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	l.log = log
+
+	return l, nil
+}
+
+func (l *FileAuditLog) Append(_ context.Context, entry AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.ID = uint64(len(l.offsets)) + 1
+	offset := l.log.Size()
+	if err := l.log.Append(entry); err != nil {
+		return fmt.Errorf("append audit entry: %w", err)
+	}
+	l.offsets = append(l.offsets, offset)
+
+	return nil
+}
+
+// Query reads the entries after query.After from the file, without holding
+// back the entries appended meanwhile.
+func (l *FileAuditLog) Query(_ context.Context, query AuditQuery) ([]AuditEntry, bool, error) {
+	l.mu.Lock()
+	if query.After >= uint64(len(l.offsets)) {
+		l.mu.Unlock()
+		return nil, false, nil
+	}
+	start, end := l.offsets[query.After], l.log.Size()
+	l.mu.Unlock()
+
+	limit := query.limit()
+	var matched []AuditEntry
+	more := false
+	err := l.log.ReadLines(start, end, func(line []byte) (bool, error) {
+		var entry AuditEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return false, fmt.Errorf("decode audit entry: %w", err)
+		}
+		if !query.match(entry) {
+			return true, nil
+		}
+		if len(matched) == limit {
+			more = true
+			return false, nil
+		}
+		matched = append(matched, entry)
+		return true, nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("read audit log: %w", err)
+	}
+
+	return matched, more, nil
+}
+
+func (l *FileAuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.log.Close()
+}
@@ -0,0 +1,81 @@
+package manager_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/namlh/vulcanLabsOA/manager"
+	"github.com/namlh/vulcanLabsOA/testing/assert"
+)
+
+func TestFileAuditLog_Reopen(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	auditLog, err := manager.NewFileAuditLog(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, auditLog.Append(ctx, manager.AuditEntry{Operation: manager.AuditOperationReserve}))
+	assert.NoError(t, auditLog.Append(ctx, manager.AuditEntry{Operation: manager.AuditOperationCancel}))
+	assert.NoError(t, auditLog.Close())
+
+	// simulate a crash in the middle of writing an entry
+	f, err := os.OpenFile(filepath.Join(dir, "audit.log"), os.O_WRONLY|os.O_APPEND, 0o644)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"id":3,"oper`)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	auditLog, err = manager.NewFileAuditLog(dir)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = auditLog.Close() })
+	assert.NoError(t, auditLog.Append(ctx, manager.AuditEntry{Operation: manager.AuditOperationHold}))
+
+	entries, more, err := auditLog.Query(ctx, manager.AuditQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, false, more)
+	assert.Equal(t, 3, len(entries))
+	assert.Equal(t, uint64(3), entries[2].ID)
+	assert.Equal(t, manager.AuditOperationHold, entries[2].Operation)
+}
+
+func TestFileAuditLog_Query(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	auditLog, err := manager.NewFileAuditLog(dir)
+	assert.NoError(t, err)
+	for _, operation := range []string{
+		manager.AuditOperationReserve,
+		manager.AuditOperationCancel,
+		manager.AuditOperationReserve,
+		manager.AuditOperationReserve,
+	} {
+		assert.NoError(t, auditLog.Append(ctx, manager.AuditEntry{Operation: operation}))
+	}
+	assert.NoError(t, auditLog.Close())
+
+	auditLog, err = manager.NewFileAuditLog(dir)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = auditLog.Close() })
+
+	entries, more, err := auditLog.Query(ctx, manager.AuditQuery{Operation: manager.AuditOperationReserve, After: 1, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, true, more)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, uint64(3), entries[0].ID)
+
+	entries, more, err = auditLog.Query(ctx, manager.AuditQuery{Operation: manager.AuditOperationReserve, After: 3, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, false, more)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, uint64(4), entries[0].ID)
+
+	entries, more, err = auditLog.Query(ctx, manager.AuditQuery{After: 4})
+	assert.NoError(t, err)
+	assert.Equal(t, false, more)
+	assert.Equal(t, 0, len(entries))
+}
@@ -0,0 +1,169 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/namlh/vulcanLabsOA/util/jsonlog"
+)
+
+const defaultSnapshotEvery = 1000
+
+// FileRoomStore keeps an append-only write-ahead log of mutations next to
+// a snapshot of the room. Every snapshotEvery appends the log is folded
+// into a fresh snapshot and truncated.
+//
+// Replaying the log on top of a snapshot that already contains it yields
+// the same state, so a crash between writing the snapshot and truncating
+// the log is harmless.
+type FileRoomStore struct {
+	logger        *slog.Logger
+	mu            *sync.Mutex
+	walPath       string
+	snapshotPath  string
+	wal           *jsonlog.Log
+	seats         *seatTable
+	snapshotEvery int
+	numAppended   int
+}
+
+func NewFileRoomStore(logger *slog.Logger, dir, roomID string, snapshotEvery int) (*FileRoomStore, error) {
+	if dir == "" {
+		return nil, errors.New("store dir must not be empty")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create store dir: %w", err)
+	}
+	if snapshotEvery <= 0 {
+		snapshotEvery = defaultSnapshotEvery
+	}
+
+	return &FileRoomStore{
+		logger:        logger,
+		mu:            new(sync.Mutex),
+		walPath:       filepath.Join(dir, roomID+".wal"),
+		snapshotPath:  filepath.Join(dir, roomID+".snapshot"),
+		seats:         newSeatTable(),
+		snapshotEvery: snapshotEvery,
+	}, nil
+}
+
+func (s *FileRoomStore) Load(_ context.Context) (empty Snapshot, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wal != nil {
+		return s.seats.snapshot(), nil
+	}
+
+	buf, err := os.ReadFile(s.snapshotPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return empty, fmt.Errorf("read snapshot: %w", err)
+	}
+	if err == nil {
+		var snapshot Snapshot
+		if err = json.Unmarshal(buf, &snapshot); err != nil {
+			return empty, fmt.Errorf("decode snapshot: %w", err)
+		}
+		s.seats.load(snapshot)
+	}
+
+	wal, err := jsonlog.Open(s.walPath, func(line []byte) error {
+		var mutation Mutation
+		if err := json.Unmarshal(line, &mutation); err != nil {
+			return err
+		}
+		s.seats.apply(mutation)
+		s.numAppended++
+		return nil
+	})
+	if err != nil {
+		return empty, fmt.Errorf("open wal: %w", err)
+	}
+	s.wal = wal
+
+	return s.seats.snapshot(), nil
+}
+
+func (s *FileRoomStore) Append(_ context.Context, mutation Mutation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wal == nil {
+		return errors.New("store is not loaded")
+	}
+
+	if err := s.wal.Append(mutation); err != nil {
+		return fmt.Errorf("append wal: %w", err)
+	}
+
+	s.seats.apply(mutation)
+	s.numAppended++
+
+	if s.numAppended >= s.snapshotEvery {
+		// the mutation is already durable in the wal, a failed snapshot
+		// is retried on the next append
+		if err := s.snapshot(); err != nil {
+			s.logger.Error("snapshot failed", "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *FileRoomStore) snapshot() error {
+	buf, err := json.Marshal(s.seats.snapshot())
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+
+	tmpPath := s.snapshotPath + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("create snapshot: %w", err)
+	}
+	if _, err = f.Write(buf); err != nil {
+		return errors.Join(fmt.Errorf("write snapshot: %w", err), f.Close())
+	}
+	if err = f.Sync(); err != nil {
+		return errors.Join(fmt.Errorf("sync snapshot: %w", err), f.Close())
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("close snapshot: %w", err)
+	}
+
+	if err = os.Rename(tmpPath, s.snapshotPath); err != nil {
+		return fmt.Errorf("rename snapshot: %w", err)
+	}
+	if err = jsonlog.SyncDir(filepath.Dir(s.snapshotPath)); err != nil {
+		return fmt.Errorf("sync store dir: %w", err)
+	}
+
+	if err = s.wal.Rewrite(nil); err != nil {
+		return fmt.Errorf("truncate wal: %w", err)
+	}
+	s.numAppended = 0
+
+	return nil
+}
+
+func (s *FileRoomStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wal == nil {
+		return nil
+	}
+
+	err := s.wal.Close()
+	s.wal = nil
+
+	return err
+}
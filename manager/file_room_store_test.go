@@ -0,0 +1,113 @@
+package manager_test
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/namlh/vulcanLabsOA/config"
+	"github.com/namlh/vulcanLabsOA/manager"
+	"github.com/namlh/vulcanLabsOA/testing/assert"
+)
+
+func TestFileRoomStore_Restore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	logger := slog.Default()
+	dir := t.TempDir()
+	cfg := config.Room{ID: "main", NumRows: 4, NumCols: 4, MinDistance: 3}
+	groupManager := manager.NewGroupManager([]string{"abc", "xyz"})
+
+	newRoom := func() manager.RoomManager {
+		store, err := manager.NewFileRoomStore(logger, dir, cfg.ID, 2)
+		assert.NoError(t, err)
+		room := manager.NewRoomManager(logger, &cfg, &config.Hold{}, groupManager, store, nil, nil)
+		assert.NoError(t, room.Restore(ctx))
+		return room
+	}
+
+	room := newRoom()
+	_, err := room.ReserveSeats(ctx, []manager.Seat{{GroupID: "abc", Coordinate: manager.Coordinate{0, 0}}})
+	assert.NoError(t, err)
+	booking, err := room.ReserveSeats(ctx, []manager.Seat{{GroupID: "xyz", Coordinate: manager.Coordinate{3, 3}}})
+	assert.NoError(t, err)
+	assert.NoError(t, room.CancelSeats(ctx, []manager.Coordinate{{0, 0}}))
+	assert.NoError(t, room.Close())
+
+	// simulate a crash in the middle of writing a record
+	f, err := os.OpenFile(filepath.Join(dir, cfg.ID+".wal"), os.O_WRONLY|os.O_APPEND, 0o644)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"reserved":[{"group_id":"abc","posi`)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	room = newRoom()
+	t.Cleanup(func() { _ = room.Close() })
+
+	seats, _, err := room.ListAvailableSeats(ctx, "xyz")
+	assert.NoError(t, err)
+	assert.Equal(t, 15, len(seats["xyz"]))
+
+	_, err = room.ReserveSeats(ctx, []manager.Seat{{GroupID: "abc", Coordinate: manager.Coordinate{3, 3}}})
+	assert.Equal(t, manager.SeatErrorCodeSeatTaken, errCode(err))
+
+	_, err = room.ReserveSeats(ctx, []manager.Seat{{GroupID: "abc", Coordinate: manager.Coordinate{0, 0}}})
+	assert.NoError(t, err)
+
+	restored, err := room.GetBooking(ctx, booking.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(restored.Seats))
+}
+
+func TestFileRoomStore_RestoreReconfiguration(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	logger := slog.Default()
+	dir := t.TempDir()
+	cfg := config.Room{ID: "main", NumRows: 4, NumCols: 4, MinDistance: 1}
+	groupManager := manager.NewGroupManager([]string{"abc"})
+
+	newRoom := func() manager.RoomManager {
+		store, err := manager.NewFileRoomStore(logger, dir, cfg.ID, 2)
+		assert.NoError(t, err)
+		room := manager.NewRoomManager(logger, &cfg, &config.Hold{}, groupManager, store, nil, nil)
+		assert.NoError(t, room.Restore(ctx))
+		return room
+	}
+
+	room := newRoom()
+	_, err := room.ReserveSeats(ctx, []manager.Seat{{GroupID: "abc", Coordinate: manager.Coordinate{3, 3}}})
+	assert.NoError(t, err)
+	reconfiguration, err := room.Reconfigure(ctx, config.Room{NumRows: 2, NumCols: 3, MinDistance: 2, Layout: []string{"SSS", "S_S"}}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, true, reconfiguration.Applied)
+	_, err = room.ReserveSeats(ctx, []manager.Seat{{GroupID: "abc", Coordinate: manager.Coordinate{0, 0}}})
+	assert.NoError(t, err)
+	assert.NoError(t, room.Close())
+
+	room = newRoom()
+	t.Cleanup(func() { _ = room.Close() })
+
+	restored := room.Config(ctx)
+	assert.Equal(t, 2, restored.NumRows)
+	assert.Equal(t, 3, restored.NumCols)
+	assert.Equal(t, 2, restored.MinDistance)
+	assert.Equal(t, "SSS,S_S", strings.Join(restored.Layout, ","))
+	assert.Equal(t, 1, len(room.ListReservations(ctx, "")))
+}
+
+// errCode returns the code of the first seat violation in err.
+func errCode(err error) manager.SeatErrorCode {
+	if sErrs, ok := err.(manager.SeatErrors); ok && len(sErrs) > 0 {
+		return sErrs[0].Code
+	}
+	if sErr, ok := err.(manager.SeatError); ok {
+		return sErr.Code
+	}
+	return 0
+}
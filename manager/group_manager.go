@@ -1,32 +1,201 @@
 package manager
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/namlh/vulcanLabsOA/config"
+	"github.com/namlh/vulcanLabsOA/util/jsonlog"
+)
+
+const (
+	ErrGroupIDExists = Error("group id already exists")
+)
 
 type GroupManager interface {
 	ListGroupIDs(ctx context.Context) []string
 	HasGroupID(ctx context.Context, groupID string) bool
+	GetGroup(ctx context.Context, groupID string) (Group, error)
+	AddGroup(ctx context.Context, groupID string) (Group, error)
+	DeleteGroup(ctx context.Context, groupID string) error
+	// Generation changes whenever a group is added or deleted.
+	Generation(ctx context.Context) uint64
+}
+
+type Group struct {
+	ID string `json:"id"`
 }
 
 type DefaultGroupManager struct {
-	groups []string
+	mu         *sync.RWMutex
+	groups     []string
+	groupSet   map[string]struct{}
+	generation uint64
+	// log records the groups added and deleted at runtime when the rooms
+	// are stored in files, nil otherwise
+	log *jsonlog.Log
+}
+
+const (
+	groupChangeAdd    = "add"
+	groupChangeDelete = "delete"
+)
+
+// groupChange is a line of the group log.
+type groupChange struct {
+	Op      string `json:"op"`
+	GroupID string `json:"group_id"`
 }
 
 func NewGroupManager(groups []string) GroupManager {
-	return &DefaultGroupManager{
-		groups: groups,
+	m := &DefaultGroupManager{
+		mu:       new(sync.RWMutex),
+		groups:   make([]string, 0, len(groups)),
+		groupSet: make(map[string]struct{}, len(groups)),
+	}
+	for _, groupID := range groups {
+		if _, ok := m.groupSet[groupID]; ok {
+			continue
+		}
+		m.groups = append(m.groups, groupID)
+		m.groupSet[groupID] = struct{}{}
+	}
+
+	return m
+}
+
+// NewStoredGroupManager keeps the groups added and deleted at runtime next
+// to the rooms when they are stored in files, so that the groups of the
+// stored seats outlast a restart. The changes are replayed on top of the
+// configured groups.
+func NewStoredGroupManager(cfg *config.Store, groups []string) (*DefaultGroupManager, error) {
+	m := NewGroupManager(groups).(*DefaultGroupManager)
+	if cfg.Type != StoreTypeFile {
+		return m, nil
+	}
+	if cfg.Dir == "" {
+		return nil, errors.New("store dir must not be empty")
+	}
+
+	log, err := jsonlog.Open(filepath.Join(cfg.Dir, "groups.log"), func(line []byte) error {
+		var change groupChange
+		if err := json.Unmarshal(line, &change); err != nil {
+			return err
+		}
+		m.apply(change)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("open group log: %w", err)
+	}
+	m.log = log
+
+	return m, nil
+}
+
+// apply skips a change that is already applied, so that the log can be
+// replayed. The caller must hold m.mu.
+func (m *DefaultGroupManager) apply(change groupChange) {
+	_, ok := m.groupSet[change.GroupID]
+	switch {
+	case change.Op == groupChangeAdd && !ok:
+		m.groups = append(m.groups, change.GroupID)
+		m.groupSet[change.GroupID] = struct{}{}
+	case change.Op == groupChangeDelete && ok:
+		m.groups = slices.DeleteFunc(m.groups, func(e string) bool {
+			return e == change.GroupID
+		})
+		delete(m.groupSet, change.GroupID)
+	default:
+		return
+	}
+	m.generation++
+}
+
+// record durably logs change before it is applied. The caller must hold
+// m.mu.
+func (m *DefaultGroupManager) record(change groupChange) error {
+	if m.log == nil {
+		return nil
+	}
+	if err := m.log.Append(change); err != nil {
+		return fmt.Errorf("append group change: %w", err)
 	}
+
+	return nil
+}
+
+func (m *DefaultGroupManager) Close() error {
+	if m.log == nil {
+		return nil
+	}
+
+	return m.log.Close()
 }
 
 func (m *DefaultGroupManager) ListGroupIDs(ctx context.Context) []string {
-	return m.groups
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.Clone(m.groups)
 }
 
 func (m *DefaultGroupManager) HasGroupID(ctx context.Context, groupID string) bool {
-	for _, e := range m.ListGroupIDs(ctx) {
-		if groupID == e {
-			return true
-		}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.groupSet[groupID]
+	return ok
+}
+
+func (m *DefaultGroupManager) GetGroup(ctx context.Context, groupID string) (Group, error) {
+	if !m.HasGroupID(ctx, groupID) {
+		return Group{}, ErrGroupIdNotFound
 	}
 
-	return false
+	return Group{ID: groupID}, nil
+}
+
+func (m *DefaultGroupManager) AddGroup(_ context.Context, groupID string) (Group, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.groupSet[groupID]; ok {
+		return Group{}, ErrGroupIDExists
+	}
+	change := groupChange{Op: groupChangeAdd, GroupID: groupID}
+	if err := m.record(change); err != nil {
+		return Group{}, err
+	}
+	m.apply(change)
+
+	return Group{ID: groupID}, nil
+}
+
+func (m *DefaultGroupManager) DeleteGroup(_ context.Context, groupID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.groupSet[groupID]; !ok {
+		return ErrGroupIdNotFound
+	}
+	change := groupChange{Op: groupChangeDelete, GroupID: groupID}
+	if err := m.record(change); err != nil {
+		return err
+	}
+	m.apply(change)
+
+	return nil
+}
+
+func (m *DefaultGroupManager) Generation(_ context.Context) uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.generation
 }
@@ -0,0 +1,34 @@
+package manager_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/namlh/vulcanLabsOA/config"
+	"github.com/namlh/vulcanLabsOA/manager"
+	"github.com/namlh/vulcanLabsOA/testing/assert"
+)
+
+func TestStoredGroupManager(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	cfg := &config.Store{Type: manager.StoreTypeFile, Dir: t.TempDir()}
+
+	groupManager, err := manager.NewStoredGroupManager(cfg, []string{"abc", "xyz"})
+	assert.NoError(t, err)
+	_, err = groupManager.AddGroup(ctx, "def")
+	assert.NoError(t, err)
+	assert.NoError(t, groupManager.DeleteGroup(ctx, "abc"))
+	assert.NoError(t, groupManager.Close())
+
+	// the runtime changes are replayed on top of the configured groups
+	groupManager, err = manager.NewStoredGroupManager(cfg, []string{"abc", "xyz", "ghi"})
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = groupManager.Close() })
+	assert.Equal(t, "xyz,ghi,def", strings.Join(groupManager.ListGroupIDs(ctx), ","))
+
+	_, err = groupManager.AddGroup(ctx, "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "xyz,ghi,def,abc", strings.Join(groupManager.ListGroupIDs(ctx), ","))
+}
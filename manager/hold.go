@@ -0,0 +1,180 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/namlh/vulcanLabsOA/util/idutil"
+)
+
+const defaultHoldTTL = 10 * time.Minute
+
+// Hold locks seats for a limited time without reserving them. Holds only
+// live in memory: losing them on restart just lets the customer pick again.
+type Hold struct {
+	ID        string    `json:"hold_id"`
+	Seats     []Seat    `json:"seats"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type heldSeat struct {
+	GroupID string
+	HoldID  string
+}
+
+func (m *DefaultRoomManager) HoldSeats(ctx context.Context, seats []Seat) (hold Hold, err error) {
+	m.mu.Lock()
+	defer m.unlock()
+	defer m.reportVersion(ctx)
+	defer func() {
+		m.record(ctx, AuditEntry{Operation: AuditOperationHold, Seats: seats, HoldID: hold.ID}, err)
+	}()
+
+	if err := m.checkVersion(ctx); err != nil {
+		return Hold{}, err
+	}
+	if err := m.validateSeats(ctx, seats); err != nil {
+		return Hold{}, err
+	}
+
+	held := &Hold{
+		ID:        idutil.New(),
+		Seats:     seats,
+		ExpiresAt: m.now().Add(m.holdTTL),
+	}
+
+	m.addHold(held)
+
+	return *held, nil
+}
+
+// ConfirmHold books the seats of a hold. It is audited as a reservation.
+func (m *DefaultRoomManager) ConfirmHold(ctx context.Context, holdID string) (booking Booking, err error) {
+	m.mu.Lock()
+	defer m.unlock()
+	defer m.reportVersion(ctx)
+
+	entry := AuditEntry{Operation: AuditOperationReserve, HoldID: holdID}
+	defer func() {
+		entry.BookingID = booking.ID
+		m.record(ctx, entry, err)
+	}()
+
+	if err := m.checkVersion(ctx); err != nil {
+		return Booking{}, err
+	}
+
+	hold, ok := m.holds[holdID]
+	if !ok {
+		return Booking{}, ErrHoldNotFound
+	}
+	entry.Seats = hold.Seats
+
+	if !m.now().Before(hold.ExpiresAt) {
+		m.releaseExpiredHold(ctx, hold)
+		m.fillWaitlist(ctx)
+		return Booking{}, ErrHoldExpired
+	}
+
+	// the booking is recorded before the hold is released, so that a
+	// failure leaves the hold untouched and publishes nothing
+	booking, mutation := newBooking(hold.Seats)
+	if err := m.store.Append(ctx, mutation); err != nil {
+		return Booking{}, fmt.Errorf("append mutation: %w", err)
+	}
+	m.releaseHold(hold)
+	m.applyCommitted(mutation)
+
+	return booking, nil
+}
+
+// ReleaseExpiredHolds frees the seats of every expired hold and returns
+// the number of released holds.
+func (m *DefaultRoomManager) ReleaseExpiredHolds(ctx context.Context) int {
+	m.mu.Lock()
+	defer m.unlock()
+
+	now := m.now()
+	released := 0
+	for _, hold := range m.holds {
+		if now.Before(hold.ExpiresAt) {
+			continue
+		}
+
+		m.releaseExpiredHold(ctx, hold)
+		released++
+	}
+
+	if released > 0 {
+		m.logger.DebugContext(ctx, "released expired holds", "count", released)
+		m.fillWaitlist(ctx)
+	}
+
+	return released
+}
+
+func (m *DefaultRoomManager) addHold(hold *Hold) {
+	m.holds[hold.ID] = hold
+	for _, seat := range hold.Seats {
+		m.heldSeat[seat.AsIndex(m.cfg.NumCols)] = heldSeat{
+			GroupID: seat.GroupID,
+			HoldID:  hold.ID,
+		}
+		m.index.add(seat.Coordinate, seat.GroupID)
+	}
+	m.version++
+	m.publish(Event{Type: EventHold, Hold: hold.clone()})
+}
+
+func (m *DefaultRoomManager) releaseHold(hold *Hold) {
+	for _, seat := range hold.Seats {
+		delete(m.heldSeat, seat.AsIndex(m.cfg.NumCols))
+		m.index.remove(seat.Coordinate, seat.GroupID)
+	}
+	delete(m.holds, hold.ID)
+	m.version++
+	m.publish(Event{Type: EventHoldRelease, Hold: hold.clone()})
+}
+
+// releaseHeldSeats releases the held seats for which release reports true
+// and records a release audit entry per hold. The holds keep their other
+// seats. The caller must hold m.mu.
+func (m *DefaultRoomManager) releaseHeldSeats(ctx context.Context, release func(seat Seat) bool) {
+	for _, hold := range m.holds {
+		released := slices.DeleteFunc(slices.Clone(hold.Seats), func(seat Seat) bool {
+			return !release(seat)
+		})
+		if len(released) == 0 {
+			continue
+		}
+
+		m.releaseHold(hold)
+		hold.Seats = slices.DeleteFunc(hold.Seats, release)
+		if len(hold.Seats) > 0 {
+			m.addHold(hold)
+		}
+		m.record(ctx, AuditEntry{Operation: AuditOperationRelease, Seats: released, HoldID: hold.ID}, nil)
+	}
+}
+
+// releaseExpiredHold is audited on behalf of the sweeper, even when a
+// confirmation finds the hold expired first.
+func (m *DefaultRoomManager) releaseExpiredHold(ctx context.Context, hold *Hold) {
+	m.releaseHold(hold)
+	m.record(ctx, AuditEntry{
+		Operation: AuditOperationRelease,
+		Actor:     AuditActorSweeper,
+		Seats:     hold.Seats,
+		HoldID:    hold.ID,
+	}, nil)
+}
+
+func (h *Hold) clone() *Hold {
+	return &Hold{
+		ID:        h.ID,
+		Seats:     slices.Clone(h.Seats),
+		ExpiresAt: h.ExpiresAt,
+	}
+}
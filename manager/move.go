@@ -0,0 +1,103 @@
+package manager
+
+import "context"
+
+type SeatMove struct {
+	From Coordinate `json:"from"`
+	To   Coordinate `json:"to"`
+}
+
+// MoveSeats moves reserved seats to new positions, keeping their group and
+// booking. All seats are vacated before any is placed, so two seats can be
+// swapped. Distance rules are checked against the room after every move and
+// nothing changes when any move is invalid.
+func (m *DefaultRoomManager) MoveSeats(ctx context.Context, moves []SeatMove) (_ []Reservation, err error) {
+	m.mu.Lock()
+	defer m.unlock()
+	defer m.reportVersion(ctx)
+	defer m.fillWaitlistOnSuccess(ctx, &err)
+
+	from := make([]Coordinate, len(moves))
+	for i, move := range moves {
+		from[i] = move.From
+	}
+	entry := AuditEntry{Operation: AuditOperationMove, Seats: m.reservedSeats(from), Moves: moves}
+	defer func() {
+		m.record(ctx, entry, err)
+	}()
+
+	if err := m.checkVersion(ctx); err != nil {
+		return nil, err
+	}
+
+	var errs SeatErrors
+	overlay := newSeatOverlay(m)
+
+	moved := make([]Reservation, len(moves))
+	for i, move := range moves {
+		seat := Seat{Coordinate: move.From}
+		if !m.inBound(move.From) {
+			errs = append(errs, SeatError{seat, SeatErrorCodeOutOfBound, i})
+			continue
+		}
+
+		idx := move.From.AsIndex(m.cfg.NumCols)
+		reservation, ok := m.reservedSeat[idx]
+		if !ok {
+			errs = append(errs, SeatError{seat, SeatErrorCodeNotReserved, i})
+			continue
+		}
+		if _, ok := overlay.staged[idx]; ok {
+			errs = append(errs, SeatError{seat, SeatErrorCodeDuplicatedPosition, i})
+			continue
+		}
+
+		overlay.cancel(move.From, i)
+		moved[i] = Reservation{
+			Seat:      Seat{GroupID: reservation.GroupID, Coordinate: move.To},
+			BookingID: reservation.BookingID,
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	toSet := make(map[int64]struct{})
+	for i, reservation := range moved {
+		if !m.inBound(reservation.Coordinate) {
+			errs = append(errs, SeatError{reservation.Seat, SeatErrorCodeOutOfBound, i})
+			continue
+		}
+
+		idx := reservation.AsIndex(m.cfg.NumCols)
+		if !m.seatMap.isSeat(idx) {
+			errs = append(errs, SeatError{reservation.Seat, SeatErrorCodeNotASeat, i})
+			continue
+		}
+		if _, ok := toSet[idx]; ok {
+			errs = append(errs, SeatError{reservation.Seat, SeatErrorCodeDuplicatedPosition, i})
+			continue
+		}
+		toSet[idx] = struct{}{}
+
+		if overlay.occupied(idx) {
+			errs = append(errs, SeatError{reservation.Seat, SeatErrorCodeSeatTaken, i})
+			continue
+		}
+
+		overlay.reserve(reservation, i)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	if errs = overlay.violations(); len(errs) > 0 {
+		return nil, errs
+	}
+
+	if err = m.commit(ctx, overlay.mutation()); err != nil {
+		return nil, err
+	}
+
+	return moved, nil
+}
@@ -0,0 +1,266 @@
+package manager
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/namlh/vulcanLabsOA/config"
+)
+
+const (
+	ErrInvalidRoomConfig       = Error("invalid room configuration")
+	ErrReconfigurationConflict = Error("room configuration conflicts with reserved or held seats")
+)
+
+// RoomConflict is a reserved or held seat that breaks a new configuration
+// of its room. Code is out_of_bound, not_a_seat or invalid_distance.
+type RoomConflict struct {
+	Seat
+	BookingID string `json:"booking_id,omitempty"`
+	HoldID    string `json:"hold_id,omitempty"`
+	Code      string `json:"code"`
+}
+
+// RoomReconfiguration reports the conflicts of a new room configuration,
+// the waitlist entries it cannot satisfy anymore and whether it was applied.
+type RoomReconfiguration struct {
+	Room            config.Room     `json:"room"`
+	Conflicts       []RoomConflict  `json:"conflicts"`
+	DroppedWaitlist []WaitlistEntry `json:"dropped_waitlist"`
+	Applied         bool            `json:"applied"`
+}
+
+// PreviewReconfiguration lists the conflicts cfg would have with the
+// current seats without changing the room.
+func (m *DefaultRoomManager) PreviewReconfiguration(_ context.Context, cfg config.Room) (RoomReconfiguration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkReconfiguration(&cfg); err != nil {
+		return RoomReconfiguration{}, err
+	}
+
+	return RoomReconfiguration{
+		Room:            cfg,
+		Conflicts:       m.reconfigurationConflicts(&cfg),
+		DroppedWaitlist: m.unsatisfiableWaitlist(&cfg),
+	}, nil
+}
+
+// Reconfigure switches the room to cfg when it has no conflict, or when
+// force is set. In that case the reservations outside of the new bounds or
+// on a cell that is no longer a seat are cancelled and such held seats are
+// released, while the seats that are too close under the new rules are kept.
+// Waitlist entries that the new configuration can never satisfy are dropped
+// and published as waitlist_dropped events.
+// The new dimensions, minimum distance and layout are recorded in the room
+// store, so they outlast a restart and take precedence over the config file.
+func (m *DefaultRoomManager) Reconfigure(ctx context.Context, cfg config.Room, force bool) (reconfiguration RoomReconfiguration, err error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if err := m.checkReconfiguration(&cfg); err != nil {
+		return RoomReconfiguration{}, err
+	}
+
+	reconfiguration = RoomReconfiguration{
+		Room:            cfg,
+		Conflicts:       m.reconfigurationConflicts(&cfg),
+		DroppedWaitlist: m.unsatisfiableWaitlist(&cfg),
+	}
+	if len(reconfiguration.Conflicts) > 0 && !force {
+		return reconfiguration, ErrReconfigurationConflict
+	}
+
+	var cancelled []Coordinate
+	removed := make(map[Coordinate]struct{})
+	for _, conflict := range reconfiguration.Conflicts {
+		if conflict.Code != SeatErrorCodeOutOfBound.String() && conflict.Code != SeatErrorCodeNotASeat.String() {
+			continue
+		}
+		removed[conflict.Coordinate] = struct{}{}
+		if conflict.BookingID != "" {
+			cancelled = append(cancelled, conflict.Coordinate)
+		}
+	}
+
+	entry := AuditEntry{Operation: AuditOperationReconfigure, Seats: m.reservedSeats(cancelled)}
+	defer func() {
+		m.record(ctx, entry, err)
+	}()
+
+	// the cancellations and the new configuration are a single record, so a
+	// crash cannot persist one without the other
+	room := cfg
+	mutation := Mutation{Cancelled: cancelled, Room: &room}
+	if len(cancelled) > 0 {
+		if err := m.commit(ctx, mutation); err != nil {
+			return RoomReconfiguration{}, err
+		}
+	} else if err := m.store.Append(ctx, mutation); err != nil {
+		return RoomReconfiguration{}, fmt.Errorf("append mutation: %w", err)
+	}
+
+	m.releaseHeldSeats(ctx, func(seat Seat) bool {
+		_, ok := removed[seat.Coordinate]
+		return ok
+	})
+
+	m.reconfigure(&cfg)
+	m.version++
+	m.publish(Event{Type: EventReconfiguration, Room: &room})
+	m.dropWaitlistEntries(reconfiguration.DroppedWaitlist)
+	m.fillWaitlist(ctx)
+	reconfiguration.Applied = true
+
+	return reconfiguration, nil
+}
+
+// checkReconfiguration completes cfg with the settings that cannot change
+// and validates it. The caller must hold m.mu.
+func (m *DefaultRoomManager) checkReconfiguration(cfg *config.Room) error {
+	cfg.ID = m.cfg.ID
+	cfg.DistanceMetric = m.cfg.DistanceMetric
+	cfg.LayoutFile = ""
+	cfg.SeparationPairs = m.cfg.SeparationPairs
+	cfg.AffinityClusters = m.cfg.AffinityClusters
+
+	if cfg.NumRows <= 0 || cfg.NumCols <= 0 {
+		return fmt.Errorf("%w: num_rows and num_cols must be greater than 0", ErrInvalidRoomConfig)
+	}
+	resized := cfg.NumRows != m.cfg.NumRows || cfg.NumCols != m.cfg.NumCols
+	if resized && len(m.cfg.Layout) > 0 && slices.Equal(cfg.Layout, m.cfg.Layout) {
+		return fmt.Errorf("%w: the room has a layout, a new layout must be set to change its dimensions", ErrInvalidRoomConfig)
+	}
+	if _, err := newSeatMap(cfg); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidRoomConfig, err)
+	}
+	if _, err := NewSeparationPolicy(cfg); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidRoomConfig, err)
+	}
+
+	return nil
+}
+
+// reconfigurationConflicts lists the reserved and held seats that are out
+// of the bounds of cfg, not a seat of its layout or closer than its minimum
+// distance to a seat of another group, in row-major order. The caller must
+// hold m.mu.
+func (m *DefaultRoomManager) reconfigurationConflicts(cfg *config.Room) []RoomConflict {
+	seats := make([]RoomConflict, 0, len(m.reservedSeat)+len(m.heldSeat))
+	for _, reservation := range m.reservedSeat {
+		seats = append(seats, RoomConflict{Seat: reservation.Seat, BookingID: reservation.BookingID})
+	}
+	for idx, seat := range m.heldSeat {
+		seats = append(seats, RoomConflict{
+			Seat:   Seat{GroupID: seat.GroupID, Coordinate: m.indexToCoordinate(idx)},
+			HoldID: seat.HoldID,
+		})
+	}
+	slices.SortFunc(seats, func(a, b RoomConflict) int {
+		return cmp.Or(cmp.Compare(a.Row(), b.Row()), cmp.Compare(a.Col(), b.Col()))
+	})
+
+	policy, err := NewSeparationPolicy(cfg)
+	if err != nil {
+		policy = SeparationPolicy{minDistance: cfg.MinDistance}
+	}
+	layout, err := newSeatMap(cfg)
+	if err != nil {
+		layout = seatMap{numCols: cfg.NumCols}
+	}
+	index := newSeatIndex(cfg.NumRows, cfg.NumCols, layout, policy, m.metric)
+	for i, seat := range seats {
+		if seat.Row() >= cfg.NumRows || seat.Col() >= cfg.NumCols {
+			seats[i].Code = SeatErrorCodeOutOfBound.String()
+			continue
+		}
+		if !layout.isSeat(seat.AsIndex(cfg.NumCols)) {
+			seats[i].Code = SeatErrorCodeNotASeat.String()
+			continue
+		}
+		index.add(seat.Coordinate, seat.GroupID)
+	}
+
+	conflicts := make([]RoomConflict, 0)
+	for _, seat := range seats {
+		if seat.Code == "" && index.blocked(seat.AsIndex(cfg.NumCols), seat.GroupID) {
+			seat.Code = SeatErrorCodeInvalidDistance.String()
+		}
+		if seat.Code != "" {
+			conflicts = append(conflicts, seat)
+		}
+	}
+
+	return conflicts
+}
+
+// unsatisfiableWaitlist lists the waitlist entries that cfg can never
+// satisfy: entries for a seat out of its bounds or off its seat map, and
+// entries for more seats than it has. The caller must hold m.mu.
+func (m *DefaultRoomManager) unsatisfiableWaitlist(cfg *config.Room) []WaitlistEntry {
+	layout, err := newSeatMap(cfg)
+	if err != nil {
+		layout = seatMap{numCols: cfg.NumCols}
+	}
+
+	entries := make([]WaitlistEntry, 0)
+	for _, entry := range m.waitlist {
+		unsatisfiable := entry.Count > layout.numSeats(cfg.NumRows) ||
+			slices.ContainsFunc(entry.Seats, func(coord Coordinate) bool {
+				return coord[0] >= cfg.NumRows || coord[1] >= cfg.NumCols || !layout.isSeat(coord.AsIndex(cfg.NumCols))
+			})
+		if unsatisfiable {
+			entries = append(entries, *entry)
+		}
+	}
+
+	return entries
+}
+
+// reconfigure switches the room to cfg and rebuilds everything that depends
+// on its dimensions. Seats out of the new bounds or off its seat map are
+// dropped.
+// The caller must hold m.mu.
+func (m *DefaultRoomManager) reconfigure(cfg *config.Room) {
+	layout, err := newSeatMap(cfg)
+	if err != nil {
+		m.logger.Warn("fallback to a full seat map", "error", err)
+		layout = seatMap{numCols: cfg.NumCols}
+	}
+
+	policy, err := NewSeparationPolicy(cfg)
+	if err != nil {
+		m.logger.Warn("fallback to the room's min distance", "error", err)
+		policy = SeparationPolicy{minDistance: cfg.MinDistance}
+	}
+
+	reservedSeats := m.reservedSeat
+	heldSeats := m.heldSeat
+	oldCfg := m.cfg
+
+	m.cfg = cfg
+	m.seatMap = layout
+	m.policy = policy
+	m.index = newSeatIndex(cfg.NumRows, cfg.NumCols, layout, policy, m.metric)
+	m.reservedSeat = make(map[int64]Reservation, len(reservedSeats))
+	m.heldSeat = make(map[int64]heldSeat, len(heldSeats))
+
+	for _, reservation := range reservedSeats {
+		if !m.inBound(reservation.Coordinate) || !layout.isSeat(reservation.AsIndex(cfg.NumCols)) {
+			continue
+		}
+		m.reservedSeat[reservation.AsIndex(cfg.NumCols)] = reservation
+		m.index.add(reservation.Coordinate, reservation.GroupID)
+	}
+	for idx, seat := range heldSeats {
+		coord := Coordinate{int(idx / int64(oldCfg.NumCols)), int(idx % int64(oldCfg.NumCols))}
+		if !m.inBound(coord) || !layout.isSeat(coord.AsIndex(cfg.NumCols)) {
+			continue
+		}
+		m.heldSeat[coord.AsIndex(cfg.NumCols)] = seat
+		m.index.add(coord, seat.GroupID)
+	}
+}
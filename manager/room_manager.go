@@ -1,14 +1,17 @@
 package manager
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"maps"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/namlh/vulcanLabsOA/config"
-	"github.com/namlh/vulcanLabsOA/util/mathutil"
 )
 
 type Error string
@@ -19,205 +22,561 @@ func (e Error) Error() string {
 
 const (
 	ErrGroupIdNotFound = Error("group id not found")
+	ErrHoldNotFound    = Error("hold not found")
+	ErrHoldExpired     = Error("hold expired")
 )
 
-type SeatErrorCode int
-
-const (
-	SeatErrorCodeOutOfBound SeatErrorCode = iota + 1
-	SeatErrorCodeSeatTaken
-	SeatErrorCodeInvalidDistance
-	SeatErrorCodeGroupIDNotFound
-	SeatErrorCodeNotReserved
-	SeatErrorCodeDuplicatedPosition
-)
-
-type SeatError struct {
-	seat  Seat
-	Code  SeatErrorCode
-	index int
-}
-
-func (e SeatError) Error() string {
-	switch e.Code {
-	case SeatErrorCodeOutOfBound:
-		return fmt.Sprintf("position [%d,%d] at index %d out of bound", e.seat.Row(), e.seat.Col(), e.index)
-	case SeatErrorCodeSeatTaken:
-		return fmt.Sprintf("position [%d,%d] at index %d has already been taken", e.seat.Row(), e.seat.Col(), e.index)
-	case SeatErrorCodeInvalidDistance:
-		return fmt.Sprintf("position [%d,%d] at index %d violate min distance constraint", e.seat.Row(), e.seat.Col(), e.index)
-	case SeatErrorCodeGroupIDNotFound:
-		return fmt.Sprintf("group_id %s at index %d not found", e.seat.GroupID, e.index)
-	case SeatErrorCodeNotReserved:
-		return fmt.Sprintf("position [%d,%d] at index %d did not get reserved", e.seat.Row(), e.seat.Col(), e.index)
-	case SeatErrorCodeDuplicatedPosition:
-		return fmt.Sprintf("position [%d,%d] at index %d is duplicated", e.seat.Row(), e.seat.Col(), e.index)
-	}
-
-	return ""
-}
-
 type RoomManager interface {
-	ListAvailableSeats(ctx context.Context, groupID string) (map[string][]Coordinate, error)
-	ReserveSeats(ctx context.Context, seats []Seat) error
+	Config(ctx context.Context) config.Room
+	Restore(ctx context.Context) error
+	Close() error
+	Version(ctx context.Context) uint64
+	ListAvailableSeats(ctx context.Context, groupID string) (map[string][]Coordinate, uint64, error)
+	ListAvailableSeatsAround(ctx context.Context, seats []Coordinate) SeatArea
+	ReserveSeats(ctx context.Context, seats []Seat) (Booking, error)
+	ReserveSeatsBestEffort(ctx context.Context, seats []Seat) (Booking, SeatErrors, error)
+	ValidateSeats(ctx context.Context, seats []Seat) error
 	CancelSeats(ctx context.Context, seats []Coordinate) error
+	MoveSeats(ctx context.Context, moves []SeatMove) ([]Reservation, error)
+	ApplyTransaction(ctx context.Context, ops []Operation) (Mutation, error)
+	HoldSeats(ctx context.Context, seats []Seat) (Hold, error)
+	ConfirmHold(ctx context.Context, holdID string) (Booking, error)
+	GetBooking(ctx context.Context, bookingID string) (Booking, error)
+	CancelBooking(ctx context.Context, bookingID string, seats []Coordinate) error
+	AllocateSeats(ctx context.Context, groupID string, count int) (Booking, error)
+	ReleaseExpiredHolds(ctx context.Context) int
+	ListReservations(ctx context.Context, groupID string) []Reservation
+	JoinWaitlist(ctx context.Context, groupID string, count int, seats []Coordinate) (WaitlistEntry, error)
+	ListWaitlist(ctx context.Context) []WaitlistEntry
+	LeaveWaitlist(ctx context.Context, entryID string) error
+	PreviewReconfiguration(ctx context.Context, cfg config.Room) (RoomReconfiguration, error)
+	Reconfigure(ctx context.Context, cfg config.Room, force bool) (RoomReconfiguration, error)
 }
 
 type DefaultRoomManager struct {
 	logger       *slog.Logger
 	cfg          *config.Room
 	mu           *sync.Mutex
-	reservedSeat map[int64]string
+	reservedSeat map[int64]Reservation
+	bookings     map[string]*Booking
+	heldSeat     map[int64]heldSeat
+	holds        map[string]*Hold
+	waitlist     []*WaitlistEntry
+	seatMap      seatMap
+	metric       DistanceMetric
+	policy       SeparationPolicy
+	index        *seatIndex
+	holdTTL      time.Duration
+	now          func() time.Time
 	groupManager GroupManager
+	store        RoomStore
+	events       EventPublisher
+	audit        AuditLog
+	// unpublished and unrecorded queue the events and audit entries of the
+	// mutations under mu, flushMu orders writing them once mu is released
+	flushMu     *sync.Mutex
+	unpublished []Event
+	unrecorded  []auditRecord
+	// version is seeded with the creation time so that versions handed out
+	// before a restart are never reused
+	version uint64
 }
 
 func NewRoomManager(
 	logger *slog.Logger,
 	cfg *config.Room,
+	holdCfg *config.Hold,
 	groupManager GroupManager,
+	store RoomStore,
+	events EventPublisher,
+	audit AuditLog,
 ) RoomManager {
+	return newRoomManager(logger, cfg, holdCfg, groupManager, store, events, audit)
+}
+
+func newRoomManager(
+	logger *slog.Logger,
+	cfg *config.Room,
+	holdCfg *config.Hold,
+	groupManager GroupManager,
+	store RoomStore,
+	events EventPublisher,
+	audit AuditLog,
+) *DefaultRoomManager {
+	holdTTL := holdCfg.TTL
+	if holdTTL <= 0 {
+		holdTTL = defaultHoldTTL
+	}
+
+	metric, err := NewDistanceMetric(cfg.DistanceMetric)
+	if err != nil {
+		logger.Warn("fallback to manhattan distance", "error", err)
+		metric = ManhattanDistance{}
+	}
+
+	layout, err := newSeatMap(cfg)
+	if err != nil {
+		logger.Warn("fallback to a full seat map", "error", err)
+		layout = seatMap{numCols: cfg.NumCols}
+	}
+
+	policy, err := NewSeparationPolicy(cfg)
+	if err != nil {
+		logger.Warn("fallback to the room's min distance", "error", err)
+		policy = SeparationPolicy{minDistance: cfg.MinDistance}
+	}
+
 	return &DefaultRoomManager{
 		logger:       logger,
 		cfg:          cfg,
 		mu:           new(sync.Mutex),
-		reservedSeat: make(map[int64]string),
+		flushMu:      new(sync.Mutex),
+		reservedSeat: make(map[int64]Reservation),
+		bookings:     make(map[string]*Booking),
+		heldSeat:     make(map[int64]heldSeat),
+		holds:        make(map[string]*Hold),
+		seatMap:      layout,
+		metric:       metric,
+		policy:       policy,
+		index:        newSeatIndex(cfg.NumRows, cfg.NumCols, layout, policy, metric),
+		holdTTL:      holdTTL,
+		now:          time.Now,
 		groupManager: groupManager,
+		store:        store,
+		events:       events,
+		audit:        audit,
+		version:      initialVersion(time.Now()),
 	}
 }
 
-func (m *DefaultRoomManager) ListAvailableSeats(ctx context.Context, groupID string) (map[string][]Coordinate, error) {
+func (m *DefaultRoomManager) Config(_ context.Context) config.Room {
 	m.mu.Lock()
-	reservedSeats := maps.Clone(m.reservedSeat)
-	m.mu.Unlock()
+	defer m.mu.Unlock()
+
+	return *m.cfg
+}
+
+// Restore replaces the in-memory state with the one recorded in the store.
+func (m *DefaultRoomManager) Restore(ctx context.Context) error {
+	snapshot, err := m.store.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("load store: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.unlock()
+
+	// only the settings a reconfiguration can change are restored, the
+	// others still come from the config file
+	if snapshot.Room != nil {
+		cfg := *m.cfg
+		cfg.NumRows = snapshot.Room.NumRows
+		cfg.NumCols = snapshot.Room.NumCols
+		cfg.MinDistance = snapshot.Room.MinDistance
+		cfg.Layout = snapshot.Room.Layout
+		if err := m.checkReconfiguration(&cfg); err != nil {
+			m.logger.WarnContext(ctx, "skip restoring room configuration", "error", err)
+		} else {
+			m.reconfigure(&cfg)
+		}
+	}
+
+	m.reservedSeat = make(map[int64]Reservation, len(snapshot.Seats))
+	m.bookings = make(map[string]*Booking)
+	m.index = newSeatIndex(m.cfg.NumRows, m.cfg.NumCols, m.seatMap, m.policy, m.metric)
+	for idx, seat := range m.heldSeat {
+		m.index.add(m.indexToCoordinate(idx), seat.GroupID)
+	}
+
+	reservations := make([]Reservation, 0, len(snapshot.Seats))
+	for _, reservation := range snapshot.Seats {
+		if !m.inBound(reservation.Coordinate) || !m.seatMap.isSeat(reservation.AsIndex(m.cfg.NumCols)) {
+			m.logger.WarnContext(ctx, "skip restoring invalid seat", "position", reservation.Coordinate, "group_id", reservation.GroupID)
+			continue
+		}
+		reservations = append(reservations, reservation)
+	}
+	m.apply(Mutation{Reserved: reservations})
+	room := *m.cfg
+	m.publish(Event{Type: EventRestore, Reserved: reservations, Room: &room})
+
+	return nil
+}
+
+func (m *DefaultRoomManager) Close() error {
+	return m.store.Close()
+}
 
+// ListAvailableSeats returns the available seats per group together with
+// the version of the room they were computed at.
+func (m *DefaultRoomManager) ListAvailableSeats(ctx context.Context, groupID string) (map[string][]Coordinate, uint64, error) {
 	groupIDs := []string{groupID}
 	if groupID != "" && !m.groupManager.HasGroupID(ctx, groupID) {
-		return nil, ErrGroupIdNotFound
+		return nil, 0, ErrGroupIdNotFound
 	} else if groupID == "" {
 		groupIDs = m.groupManager.ListGroupIDs(ctx)
 	}
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	availableSeatBucket := make(map[string][]Coordinate)
 	for _, groupID := range groupIDs {
-		availableSeatBucket[groupID] = nil
+		availableSeatBucket[groupID] = m.availableSeats(groupID)
 	}
 
-	for i := int64(0); i < int64(m.cfg.NumRows)*int64(m.cfg.NumCols); i++ {
-		if _, ok := reservedSeats[i]; ok {
-			continue
+	return availableSeatBucket, m.version, nil
+}
+
+// availableSeats lists the free seats that groupID can take, in row-major
+// order. Only the seats around the occupied ones are checked, every
+// isolated seat is available. The caller must hold m.mu.
+func (m *DefaultRoomManager) availableSeats(groupID string) []Coordinate {
+	isolated, crowded := m.index.isolatedSeats(), m.index.crowdedSeats()
+	indexes := make([]int64, len(isolated), len(isolated)+len(crowded))
+	copy(indexes, isolated)
+	for _, idx := range crowded {
+		if !m.isOccupied(idx) && !m.index.blocked(idx, groupID) {
+			indexes = append(indexes, idx)
 		}
+	}
+	if len(indexes) == 0 {
+		return nil
+	}
 
-		candidateCoord := m.indexToCoordinate(i)
+	available := make([]Coordinate, len(indexes))
+	for i, idx := range m.index.sortRowMajor(indexes) {
+		available[i] = m.indexToCoordinate(idx)
+	}
 
-		for _, groupID := range groupIDs {
-			isValid := true
-			for k, reservedGroupID := range reservedSeats {
-				a := Seat{
-					GroupID:    reservedGroupID,
-					Coordinate: m.indexToCoordinate(k),
-				}
+	return available
+}
 
-				b := Seat{
-					GroupID:    groupID,
-					Coordinate: candidateCoord,
-				}
+func (m *DefaultRoomManager) isAvailable(idx int64, groupID string) bool {
+	if !m.seatMap.isSeat(idx) {
+		return false
+	}
+
+	return m.index.isolated(idx) || !m.isOccupied(idx) && !m.index.blocked(idx, groupID)
+}
+
+// SeatArea is the availability per group of the seats of an area of a room,
+// at Version. Seats and the available seats are in row-major order.
+type SeatArea struct {
+	Version   uint64
+	Seats     []Coordinate
+	Available map[string][]Coordinate
+}
+
+// ListAvailableSeatsAround returns the availability of every seat whose
+// availability can change when seats are taken or freed: the seats
+// themselves and the seats closer to them than the largest distance.
+func (m *DefaultRoomManager) ListAvailableSeatsAround(ctx context.Context, seats []Coordinate) SeatArea {
+	groupIDs := m.groupManager.ListGroupIDs(ctx)
 
-				if !m.isValidDistance(a, b) {
-					isValid = false
-					break
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	distance := m.index.levels[len(m.index.levels)-1].minDistance
+	reachRows, reachCols := m.metric.Reach(distance)
+	inArea := make(map[int64]struct{})
+	for _, seat := range seats {
+		for row := max(seat[0]-reachRows, 0); row <= min(seat[0]+reachRows, m.cfg.NumRows-1); row++ {
+			for col := max(seat[1]-reachCols, 0); col <= min(seat[1]+reachCols, m.cfg.NumCols-1); col++ {
+				cell := Coordinate{row, col}
+				if idx := cell.AsIndex(m.cfg.NumCols); m.seatMap.isSeat(idx) && m.metric.Closer(seat, cell, distance) {
+					inArea[idx] = struct{}{}
 				}
 			}
+		}
+	}
+	area := slices.Sorted(maps.Keys(inArea))
 
-			if isValid {
-				availableSeatBucket[groupID] = append(availableSeatBucket[groupID], candidateCoord)
+	seatArea := SeatArea{
+		Version:   m.version,
+		Seats:     make([]Coordinate, len(area)),
+		Available: make(map[string][]Coordinate, len(groupIDs)),
+	}
+	for i, idx := range area {
+		seatArea.Seats[i] = m.indexToCoordinate(idx)
+	}
+	for _, groupID := range groupIDs {
+		available := []Coordinate{}
+		for _, idx := range area {
+			if m.isAvailable(idx, groupID) {
+				available = append(available, m.indexToCoordinate(idx))
 			}
 		}
+		seatArea.Available[groupID] = available
 	}
 
-	return availableSeatBucket, nil
+	return seatArea
 }
 
-func (m *DefaultRoomManager) ReserveSeats(ctx context.Context, seats []Seat) error {
+func (m *DefaultRoomManager) ReserveSeats(ctx context.Context, seats []Seat) (booking Booking, err error) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	defer m.unlock()
+	defer m.reportVersion(ctx)
+	defer func() {
+		m.record(ctx, AuditEntry{Operation: AuditOperationReserve, Seats: seats, BookingID: booking.ID}, err)
+	}()
+
+	if err := m.checkVersion(ctx); err != nil {
+		return Booking{}, err
+	}
+	if err := m.validateSeats(ctx, seats); err != nil {
+		return Booking{}, err
+	}
 
-	numRows, numCols := m.cfg.NumRows, m.cfg.NumCols
-	idxSet := make(map[int64]struct{})
+	return m.book(ctx, seats)
+}
 
-	// validation
-	for i, seat := range seats {
-		if !m.groupManager.HasGroupID(ctx, seat.GroupID) {
-			return SeatError{seat, SeatErrorCodeGroupIDNotFound, i}
+// ReserveSeatsBestEffort reserves every seat that passes validation and
+// returns the violations of the rejected ones. The booking is empty when
+// no seat is accepted.
+func (m *DefaultRoomManager) ReserveSeatsBestEffort(ctx context.Context, seats []Seat) (booking Booking, sErrs SeatErrors, err error) {
+	m.mu.Lock()
+	defer m.unlock()
+	defer m.reportVersion(ctx)
+	defer func() {
+		entry := AuditEntry{Operation: AuditOperationReserve, Seats: seats, BookingID: booking.ID}
+		auditErr := err
+		switch {
+		case err != nil:
+		case len(sErrs) > 0 && booking.ID == "":
+			auditErr = sErrs
+		case len(sErrs) > 0:
+			entry.Result = AuditResultPartial
+			entry.Error = sErrs.Error()
 		}
+		m.record(ctx, entry, auditErr)
+	}()
 
-		if seat.Row() < 0 || seat.Row() >= numRows {
-			return SeatError{seat, SeatErrorCodeOutOfBound, i}
-		}
+	if err := m.checkVersion(ctx); err != nil {
+		return Booking{}, nil, err
+	}
+
+	if err := m.validateSeats(ctx, seats); err != nil && !errors.As(err, &sErrs) {
+		return Booking{}, nil, err
+	}
 
-		if seat.Col() < 0 || seat.Col() >= numCols {
-			return SeatError{seat, SeatErrorCodeOutOfBound, i}
+	rejected := make(map[int]struct{}, len(sErrs))
+	for _, sErr := range sErrs {
+		rejected[sErr.index] = struct{}{}
+	}
+
+	accepted := make([]Seat, 0, len(seats)-len(rejected))
+	for i, seat := range seats {
+		if _, ok := rejected[i]; !ok {
+			accepted = append(accepted, seat)
 		}
+	}
+	if len(accepted) == 0 {
+		return Booking{}, sErrs, nil
+	}
 
-		idx := seat.Coordinate.AsIndex(numCols)
+	booking, err = m.book(ctx, accepted)
+	if err != nil {
+		return Booking{}, nil, err
+	}
+
+	return booking, sErrs, nil
+}
+
+// ValidateSeats runs the same checks as ReserveSeats without reserving.
+func (m *DefaultRoomManager) ValidateSeats(ctx context.Context, seats []Seat) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-		if _, ok := m.reservedSeat[idx]; ok {
-			return SeatError{seat, SeatErrorCodeSeatTaken, i}
+	return m.validateSeats(ctx, seats)
+}
+
+func (m *DefaultRoomManager) CancelSeats(ctx context.Context, coordinates []Coordinate) (err error) {
+	if len(coordinates) == 0 {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.unlock()
+	defer m.reportVersion(ctx)
+
+	defer m.fillWaitlistOnSuccess(ctx, &err)
+	entry := AuditEntry{Operation: AuditOperationCancel, Seats: m.reservedSeats(coordinates)}
+	defer func() {
+		m.record(ctx, entry, err)
+	}()
+
+	if err := m.checkVersion(ctx); err != nil {
+		return err
+	}
+
+	var errs SeatErrors
+	idxSet := make(map[int64]struct{})
+	for i, coord := range coordinates {
+		seat := Seat{Coordinate: coord}
+		if !m.inBound(coord) {
+			errs = append(errs, SeatError{seat, SeatErrorCodeOutOfBound, i})
+			continue
 		}
 
+		idx := coord.AsIndex(m.cfg.NumCols)
 		if _, ok := idxSet[idx]; ok {
-			return SeatError{seat, SeatErrorCodeDuplicatedPosition, i}
+			errs = append(errs, SeatError{seat, SeatErrorCodeDuplicatedPosition, i})
+			continue
 		}
 		idxSet[idx] = struct{}{}
 
-		for occupyIdx, groupID := range m.reservedSeat {
-			reservedSeat := Seat{
-				GroupID:    groupID,
-				Coordinate: m.indexToCoordinate(occupyIdx),
-			}
-			if !m.isValidDistance(seat, reservedSeat) {
-				return SeatError{seat, SeatErrorCodeInvalidDistance, i}
-			}
+		if _, ok := m.reservedSeat[idx]; !ok {
+			errs = append(errs, SeatError{seat, SeatErrorCodeNotReserved, i})
 		}
 	}
-
-	for _, seat := range seats {
-		idx := seat.AsIndex(numCols)
-		m.reservedSeat[idx] = seat.GroupID
+	if len(errs) > 0 {
+		return errs
 	}
 
-	return nil
+	return m.commit(ctx, Mutation{Cancelled: coordinates})
 }
 
-func (m *DefaultRoomManager) CancelSeats(_ context.Context, coordinates []Coordinate) error {
+// ListReservations returns the reserved seats in row-major order, only
+// those of groupID when it is not empty.
+func (m *DefaultRoomManager) ListReservations(_ context.Context, groupID string) []Reservation {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	reservations := make([]Reservation, 0, len(m.reservedSeat))
+	for _, reservation := range m.reservedSeat {
+		if groupID == "" || reservation.GroupID == groupID {
+			reservations = append(reservations, reservation)
+		}
+	}
+	m.mu.Unlock()
 
-	indexes := make([]int64, len(coordinates))
-	for i, coord := range coordinates {
-		idx := coord.AsIndex(m.cfg.NumCols)
-		indexes[i] = idx
+	slices.SortFunc(reservations, func(a, b Reservation) int {
+		return cmp.Or(cmp.Compare(a.Row(), b.Row()), cmp.Compare(a.Col(), b.Col()))
+	})
 
-		if _, ok := m.reservedSeat[idx]; !ok {
-			return SeatError{Seat{Coordinate: coord}, SeatErrorCodeNotReserved, i}
+	return reservations
+}
+
+// countGroupSeats counts the seats reserved or held by groupID.
+// The caller must hold m.mu.
+func (m *DefaultRoomManager) countGroupSeats(groupID string) int {
+	count := 0
+	for _, reservation := range m.reservedSeat {
+		if reservation.GroupID == groupID {
+			count++
 		}
 	}
+	for _, seat := range m.heldSeat {
+		if seat.GroupID == groupID {
+			count++
+		}
+	}
+
+	return count
+}
 
-	for _, idx := range indexes {
-		delete(m.reservedSeat, idx)
+// releaseGroup cancels every reservation, drops every held seat and every
+// waitlist entry of groupID. The caller must hold m.mu.
+func (m *DefaultRoomManager) releaseGroup(ctx context.Context, groupID string) error {
+	var cancelled []Coordinate
+	for _, reservation := range m.reservedSeat {
+		if reservation.GroupID == groupID {
+			cancelled = append(cancelled, reservation.Coordinate)
+		}
+	}
+	if len(cancelled) > 0 {
+		entry := AuditEntry{Operation: AuditOperationCancel, Seats: m.reservedSeats(cancelled)}
+		err := m.commit(ctx, Mutation{Cancelled: cancelled})
+		m.record(ctx, entry, err)
+		if err != nil {
+			return err
+		}
 	}
 
+	m.releaseHeldSeats(ctx, func(seat Seat) bool {
+		return seat.GroupID == groupID
+	})
+
+	m.dropWaitlist(groupID)
+	m.fillWaitlist(ctx)
+
 	return nil
 }
 
-func (m *DefaultRoomManager) isValidDistance(a, b Seat) bool {
-	minDistance := 1
-	if a.GroupID != b.GroupID {
-		minDistance = m.cfg.MinDistance
+// validateSeats checks seats against every reserved and held seat and
+// against the valid seats before them, and reports every violation as
+// SeatErrors.
+// The caller must hold m.mu.
+func (m *DefaultRoomManager) validateSeats(ctx context.Context, seats []Seat) error {
+	var errs SeatErrors
+	idxSet := make(map[int64]struct{})
+
+	// valid seats are added to the index while validating so that later
+	// seats of the batch are checked against earlier ones
+	var indexed []Seat
+	defer func() {
+		for _, seat := range indexed {
+			m.index.remove(seat.Coordinate, seat.GroupID)
+		}
+	}()
+
+	for i, seat := range seats {
+		numErrs := len(errs)
+
+		hasGroupID := m.groupManager.HasGroupID(ctx, seat.GroupID)
+		if !hasGroupID {
+			errs = append(errs, SeatError{seat, SeatErrorCodeGroupIDNotFound, i})
+		}
+
+		if !m.inBound(seat.Coordinate) {
+			errs = append(errs, SeatError{seat, SeatErrorCodeOutOfBound, i})
+			continue
+		}
+
+		idx := seat.Coordinate.AsIndex(m.cfg.NumCols)
+
+		if !m.seatMap.isSeat(idx) {
+			errs = append(errs, SeatError{seat, SeatErrorCodeNotASeat, i})
+			continue
+		}
+
+		if _, ok := idxSet[idx]; ok {
+			errs = append(errs, SeatError{seat, SeatErrorCodeDuplicatedPosition, i})
+			continue
+		}
+
+		if m.isOccupied(idx) {
+			errs = append(errs, SeatError{seat, SeatErrorCodeSeatTaken, i})
+			continue
+		}
+
+		if hasGroupID && m.index.blocked(idx, seat.GroupID) {
+			errs = append(errs, SeatError{seat, SeatErrorCodeInvalidDistance, i})
+		}
+
+		if len(errs) == numErrs {
+			idxSet[idx] = struct{}{}
+			m.index.add(seat.Coordinate, seat.GroupID)
+			indexed = append(indexed, seat)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
 	}
 
-	manhattanDist := mathutil.AbsDiff(a.Row(), b.Row()) + mathutil.AbsDiff(a.Col(), b.Col())
+	return nil
+}
+
+func (m *DefaultRoomManager) isOccupied(idx int64) bool {
+	if _, ok := m.reservedSeat[idx]; ok {
+		return true
+	}
+	_, ok := m.heldSeat[idx]
+	return ok
+}
 
-	return manhattanDist >= minDistance
+func (m *DefaultRoomManager) inBound(coord Coordinate) bool {
+	return coord[0] >= 0 && coord[0] < m.cfg.NumRows && coord[1] >= 0 && coord[1] < m.cfg.NumCols
 }
 
 func (m *DefaultRoomManager) indexToCoordinate(i int64) Coordinate {
@@ -225,8 +584,8 @@ func (m *DefaultRoomManager) indexToCoordinate(i int64) Coordinate {
 }
 
 type Seat struct {
-	GroupID string
-	Coordinate
+	GroupID    string `json:"group_id"`
+	Coordinate `json:"position"`
 }
 
 func (s Seat) Row() int {
@@ -0,0 +1,467 @@
+package manager_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/namlh/vulcanLabsOA/config"
+	"github.com/namlh/vulcanLabsOA/manager"
+	"github.com/namlh/vulcanLabsOA/testing/assert"
+	"github.com/namlh/vulcanLabsOA/util/mathutil"
+)
+
+func newBenchRoom(b *testing.B, numRows, numCols, minDistance, numReserved int) manager.RoomManager {
+	b.Helper()
+	ctx := context.Background()
+
+	cfg := config.Room{ID: "bench", NumRows: numRows, NumCols: numCols, MinDistance: minDistance}
+	groupManager := manager.NewGroupManager([]string{"abc", "def", "xyz"})
+	room := manager.NewRoomManager(slog.Default(), &cfg, &config.Hold{}, groupManager, manager.NewMemoryRoomStore(), nil, nil)
+
+	groupIDs := groupManager.ListGroupIDs(ctx)
+	rng := rand.New(rand.NewPCG(1, 2))
+	for reserved := 0; reserved < numReserved; {
+		seat := manager.Seat{
+			GroupID:    groupIDs[rng.IntN(len(groupIDs))],
+			Coordinate: manager.Coordinate{rng.IntN(numRows), rng.IntN(numCols)},
+		}
+		if _, err := room.ReserveSeats(ctx, []manager.Seat{seat}); err == nil {
+			reserved++
+		}
+	}
+
+	return room
+}
+
+func BenchmarkDefaultRoomManager_ListAvailableSeats_1000x1000(b *testing.B) {
+	ctx := context.Background()
+	room := newBenchRoom(b, 1000, 1000, 5, 10_000)
+
+	b.ResetTimer()
+	for range b.N {
+		if _, _, err := room.ListAvailableSeats(ctx, "abc"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDefaultRoomManager_ReserveCancelSeats_1000x1000(b *testing.B) {
+	ctx := context.Background()
+	room := newBenchRoom(b, 1000, 1000, 5, 10_000)
+
+	seats, _, err := room.ListAvailableSeats(ctx, "abc")
+	if err != nil {
+		b.Fatal(err)
+	}
+	coord := seats["abc"][0]
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := room.ReserveSeats(ctx, []manager.Seat{{GroupID: "abc", Coordinate: coord}}); err != nil {
+			b.Fatal(err)
+		}
+		if err := room.CancelSeats(ctx, []manager.Coordinate{coord}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDefaultRoomManager_ListAvailableSeats(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	cfg := config.Room{ID: "main", NumRows: 12, NumCols: 9, MinDistance: 3}
+	cfg.Layout = make([]string, cfg.NumRows)
+	for row := range cfg.NumRows {
+		cfg.Layout[row] = "SSSS_SSSS"
+	}
+	cfg.Layout[0] = "XXSS_SSXX"
+	groupManager := manager.NewGroupManager([]string{"abc", "xyz"})
+	room := manager.NewRoomManager(slog.Default(), &cfg, &config.Hold{}, groupManager, manager.NewMemoryRoomStore(), nil, nil)
+
+	reserved := make(map[manager.Coordinate]string)
+	rng := rand.New(rand.NewPCG(3, 4))
+	for range 200 {
+		coord := manager.Coordinate{rng.IntN(cfg.NumRows), rng.IntN(cfg.NumCols)}
+		if _, ok := reserved[coord]; ok {
+			assert.NoError(t, room.CancelSeats(ctx, []manager.Coordinate{coord}))
+			delete(reserved, coord)
+			continue
+		}
+
+		groupID := []string{"abc", "xyz"}[rng.IntN(2)]
+		if _, err := room.ReserveSeats(ctx, []manager.Seat{{GroupID: groupID, Coordinate: coord}}); err == nil {
+			reserved[coord] = groupID
+		}
+	}
+
+	seats, version, err := room.ListAvailableSeats(ctx, "")
+	assert.NoError(t, err)
+	// versions stay exact in JavaScript clients
+	assert.Equal(t, true, version < 1<<53)
+
+	for _, groupID := range []string{"abc", "xyz"} {
+		var expect []manager.Coordinate
+		for row := range cfg.NumRows {
+			for col := range cfg.NumCols {
+				coord := manager.Coordinate{row, col}
+				if isAvailable(cfg, reserved, coord, groupID) {
+					expect = append(expect, coord)
+				}
+			}
+		}
+
+		assert.Equal(t, len(expect), len(seats[groupID]))
+		for i := range min(len(expect), len(seats[groupID])) {
+			assert.Equal(t, expect[i], seats[groupID][i])
+		}
+	}
+}
+
+func TestDefaultRoomManager_ListAvailableSeatsAround(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	cfg := config.Room{ID: "main", NumRows: 5, NumCols: 5, MinDistance: 2}
+	groupManager := manager.NewGroupManager([]string{"abc", "xyz"})
+	room := manager.NewRoomManager(slog.Default(), &cfg, &config.Hold{}, groupManager, manager.NewMemoryRoomStore(), nil, nil)
+	_, err := room.ReserveSeats(ctx, []manager.Seat{{GroupID: "abc", Coordinate: manager.Coordinate{0, 0}}})
+	assert.NoError(t, err)
+
+	area := room.ListAvailableSeatsAround(ctx, []manager.Coordinate{{0, 0}, {4, 4}})
+	assert.Equal(t, room.Version(ctx), area.Version)
+	assert.Equal(t, "[[0 0] [0 1] [1 0] [3 4] [4 3] [4 4]]", fmt.Sprint(area.Seats))
+	assert.Equal(t, "[[3 4] [4 3] [4 4]]", fmt.Sprint(area.Available["xyz"]))
+	assert.Equal(t, "[[0 1] [1 0] [3 4] [4 3] [4 4]]", fmt.Sprint(area.Available["abc"]))
+}
+
+// isAvailable is the brute force definition of availability.
+func isAvailable(cfg config.Room, reserved map[manager.Coordinate]string, coord manager.Coordinate, groupID string) bool {
+	if len(cfg.Layout) > 0 && cfg.Layout[coord[0]][coord[1]] != manager.CellSeat {
+		return false
+	}
+	if _, ok := reserved[coord]; ok {
+		return false
+	}
+
+	for other, otherGroupID := range reserved {
+		dist := mathutil.AbsDiff(coord[0], other[0]) + mathutil.AbsDiff(coord[1], other[1])
+		if otherGroupID != groupID && dist < cfg.MinDistance {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestDefaultRoomManager_DistanceMetric(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	testcases := []struct {
+		metric      string
+		numBlocked  int
+		diagonalErr manager.SeatErrorCode
+	}{
+		{metric: manager.DistanceMetricManhattan, numBlocked: 25},
+		{metric: manager.DistanceMetricChebyshev, numBlocked: 49, diagonalErr: manager.SeatErrorCodeInvalidDistance},
+		{metric: manager.DistanceMetricEuclidean, numBlocked: 45},
+		{metric: manager.DistanceMetricRow, numBlocked: 7},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.metric, func(t *testing.T) {
+			t.Parallel()
+			cfg := config.Room{ID: "main", NumRows: 9, NumCols: 9, MinDistance: 4, DistanceMetric: tc.metric}
+			groupManager := manager.NewGroupManager([]string{"abc", "xyz"})
+			room := manager.NewRoomManager(slog.Default(), &cfg, &config.Hold{}, groupManager, manager.NewMemoryRoomStore(), nil, nil)
+
+			_, err := room.ReserveSeats(ctx, []manager.Seat{{GroupID: "xyz", Coordinate: manager.Coordinate{4, 4}}})
+			assert.NoError(t, err)
+
+			seats, _, err := room.ListAvailableSeats(ctx, "abc")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.numBlocked, cfg.NumRows*cfg.NumCols-len(seats["abc"]))
+
+			_, err = room.ReserveSeats(ctx, []manager.Seat{{GroupID: "abc", Coordinate: manager.Coordinate{7, 7}}})
+			assert.Equal(t, tc.diagonalErr, errCode(err))
+		})
+	}
+}
+
+func TestDefaultRoomManager_SeparationPolicy(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	cfg := config.Room{
+		ID:          "main",
+		NumRows:     9,
+		NumCols:     9,
+		MinDistance: 4,
+		SeparationPairs: []config.SeparationPair{
+			{Groups: [2]string{"xyz", "abc"}, MinDistance: 6},
+			{Groups: [2]string{"staff", "guest"}, MinDistance: 1},
+			{Groups: [2]string{"family2", "guest"}, MinDistance: 3},
+		},
+		AffinityClusters: []config.AffinityCluster{
+			{Groups: []string{"family1", "family2", "guest"}},
+		},
+	}
+	groupManager := manager.NewGroupManager([]string{"abc", "def", "xyz", "staff", "guest", "family1", "family2"})
+	room := manager.NewRoomManager(slog.Default(), &cfg, &config.Hold{}, groupManager, manager.NewMemoryRoomStore(), nil, nil)
+
+	_, err := room.ReserveSeats(ctx, []manager.Seat{{GroupID: "xyz", Coordinate: manager.Coordinate{4, 4}}})
+	assert.NoError(t, err)
+
+	seats, _, err := room.ListAvailableSeats(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 57, cfg.NumRows*cfg.NumCols-len(seats["abc"]))
+	assert.Equal(t, 25, cfg.NumRows*cfg.NumCols-len(seats["def"]))
+
+	_, err = room.ReserveSeats(ctx, []manager.Seat{{GroupID: "abc", Coordinate: manager.Coordinate{8, 5}}})
+	assert.Equal(t, manager.SeatErrorCodeInvalidDistance, errCode(err))
+
+	// a cluster keeps no distance, a pair takes precedence over a cluster
+	_, err = room.ReserveSeats(ctx, []manager.Seat{
+		{GroupID: "family1", Coordinate: manager.Coordinate{0, 0}},
+		{GroupID: "family2", Coordinate: manager.Coordinate{0, 1}},
+		{GroupID: "guest", Coordinate: manager.Coordinate{2, 0}},
+		{GroupID: "staff", Coordinate: manager.Coordinate{8, 0}},
+		{GroupID: "guest", Coordinate: manager.Coordinate{8, 1}},
+	})
+	assert.NoError(t, err)
+
+	_, err = room.ReserveSeats(ctx, []manager.Seat{{GroupID: "guest", Coordinate: manager.Coordinate{1, 1}}})
+	assert.Equal(t, manager.SeatErrorCodeInvalidDistance, errCode(err))
+	_, err = room.ReserveSeats(ctx, []manager.Seat{{GroupID: "staff", Coordinate: manager.Coordinate{3, 1}}})
+	assert.Equal(t, manager.SeatErrorCodeInvalidDistance, errCode(err))
+
+	_, err = manager.NewRoomRegistry(slog.Default(), []config.Room{{
+		ID:      "main",
+		NumRows: 1,
+		NumCols: 1,
+		AffinityClusters: []config.AffinityCluster{
+			{Groups: []string{"abc", "def"}},
+			{Groups: []string{"def", "xyz"}},
+		},
+	}}, &config.Store{}, &config.Hold{}, groupManager, nil, nil)
+	assert.Equal(t, "room main: affinity cluster at index 1: group def is already in a cluster", err.Error())
+}
+
+// flakyStore fails to append while fail is set.
+type flakyStore struct {
+	*manager.MemoryRoomStore
+	fail bool
+}
+
+func (s *flakyStore) Append(ctx context.Context, mutation manager.Mutation) error {
+	if s.fail {
+		return errors.New("disk full")
+	}
+
+	return s.MemoryRoomStore.Append(ctx, mutation)
+}
+
+type eventRecorder []manager.Event
+
+func (r *eventRecorder) Publish(event manager.Event) {
+	*r = append(*r, event)
+}
+
+// blockingPublisher blocks every Publish until release is closed.
+type blockingPublisher struct {
+	published chan manager.Event
+	release   chan struct{}
+}
+
+func (p *blockingPublisher) Publish(event manager.Event) {
+	p.published <- event
+	<-p.release
+}
+
+func TestDefaultRoomManager_PublishUnlocked(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	cfg := config.Room{ID: "main", NumRows: 3, NumCols: 3, MinDistance: 2}
+	groupManager := manager.NewGroupManager([]string{"abc"})
+	events := &blockingPublisher{published: make(chan manager.Event, 1), release: make(chan struct{})}
+	room := manager.NewRoomManager(slog.Default(), &cfg, &config.Hold{}, groupManager, manager.NewMemoryRoomStore(), events, nil)
+
+	reserved := make(chan error, 1)
+	go func() {
+		_, err := room.ReserveSeats(ctx, []manager.Seat{{GroupID: "abc", Coordinate: manager.Coordinate{0, 0}}})
+		reserved <- err
+	}()
+	event := <-events.published
+
+	// the room is readable while the event is being written, and the
+	// reservation only returns once it is written
+	seats, version, err := room.ListAvailableSeats(ctx, "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, event.Version, version)
+	assert.Equal(t, 8, len(seats["abc"]))
+	select {
+	case err = <-reserved:
+		t.Fatalf("reservation returned before its event was published: %v", err)
+	default:
+	}
+
+	close(events.release)
+	assert.NoError(t, <-reserved)
+}
+
+func TestDefaultRoomManager_ConfirmHoldFailure(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	cfg := config.Room{ID: "main", NumRows: 3, NumCols: 3, MinDistance: 2}
+	groupManager := manager.NewGroupManager([]string{"abc"})
+	store := &flakyStore{MemoryRoomStore: manager.NewMemoryRoomStore()}
+	events := &eventRecorder{}
+	room := manager.NewRoomManager(slog.Default(), &cfg, &config.Hold{}, groupManager, store, events, nil)
+
+	hold, err := room.HoldSeats(ctx, []manager.Seat{{GroupID: "abc", Coordinate: manager.Coordinate{1, 1}}})
+	assert.NoError(t, err)
+	version := room.Version(ctx)
+	numEvents := len(*events)
+
+	// a failed confirmation keeps the hold and publishes nothing
+	store.fail = true
+	_, err = room.ConfirmHold(ctx, hold.ID)
+	assert.Equal(t, "append mutation: disk full", err.Error())
+	assert.Equal(t, version, room.Version(ctx))
+	assert.Equal(t, numEvents, len(*events))
+
+	store.fail = false
+	booking, err := room.ConfirmHold(ctx, hold.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(booking.Seats))
+	assert.Equal(t, numEvents+2, len(*events))
+	assert.Equal(t, manager.EventHoldRelease, (*events)[numEvents].Type)
+	assert.Equal(t, manager.EventReservation, (*events)[numEvents+1].Type)
+}
+
+func TestDefaultRoomManager_EmptyMutation(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	cfg := config.Room{ID: "main", NumRows: 3, NumCols: 3, MinDistance: 2}
+	groupManager := manager.NewGroupManager([]string{"abc"})
+	events := &eventRecorder{}
+	room := manager.NewRoomManager(slog.Default(), &cfg, &config.Hold{}, groupManager, manager.NewMemoryRoomStore(), events, nil)
+
+	// a change of no seat is neither published nor versioned
+	version := room.Version(ctx)
+	assert.NoError(t, room.CancelSeats(ctx, nil))
+	assert.Equal(t, 0, len(*events))
+	assert.Equal(t, version, room.Version(ctx))
+}
+
+func TestDefaultRoomManager_ReconfigureDropsWaitlist(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	cfg := config.Room{ID: "main", NumRows: 4, NumCols: 4, MinDistance: 1}
+	groupManager := manager.NewGroupManager([]string{"abc", "xyz"})
+	events := &eventRecorder{}
+	room := manager.NewRoomManager(slog.Default(), &cfg, &config.Hold{}, groupManager, manager.NewMemoryRoomStore(), events, nil)
+
+	_, err := room.ReserveSeats(ctx, []manager.Seat{
+		{GroupID: "abc", Coordinate: manager.Coordinate{0, 1}},
+		{GroupID: "abc", Coordinate: manager.Coordinate{3, 3}},
+	})
+	assert.NoError(t, err)
+	outOfBound, err := room.JoinWaitlist(ctx, "xyz", 0, []manager.Coordinate{{3, 3}})
+	assert.NoError(t, err)
+	tooMany, err := room.JoinWaitlist(ctx, "xyz", 16, nil)
+	assert.NoError(t, err)
+	kept, err := room.JoinWaitlist(ctx, "xyz", 0, []manager.Coordinate{{0, 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(room.ListWaitlist(ctx)))
+
+	numEvents := len(*events)
+	reconfiguration, err := room.Reconfigure(ctx, config.Room{NumRows: 3, NumCols: 4, MinDistance: 1}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(reconfiguration.DroppedWaitlist))
+	assert.Equal(t, outOfBound.ID, reconfiguration.DroppedWaitlist[0].ID)
+	assert.Equal(t, tooMany.ID, reconfiguration.DroppedWaitlist[1].ID)
+
+	waitlist := room.ListWaitlist(ctx)
+	assert.Equal(t, 1, len(waitlist))
+	assert.Equal(t, kept.ID, waitlist[0].ID)
+
+	var dropped []string
+	for _, event := range (*events)[numEvents:] {
+		if event.Type == manager.EventWaitlistDropped {
+			dropped = append(dropped, event.Waitlist.ID)
+		}
+	}
+	assert.Equal(t, 2, len(dropped))
+	assert.Equal(t, outOfBound.ID, dropped[0])
+	assert.Equal(t, tooMany.ID, dropped[1])
+}
+
+func TestDefaultRoomManager_AuditReleasedHolds(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	cfg := config.Room{ID: "main", NumRows: 4, NumCols: 4, MinDistance: 1}
+	groupManager := manager.NewGroupManager([]string{"abc", "xyz"})
+	auditLog := manager.NewMemoryAuditLog()
+	roomRegistry, err := manager.NewRoomRegistry(slog.Default(), []config.Room{cfg}, &config.Store{}, &config.Hold{}, groupManager, nil, auditLog)
+	assert.NoError(t, err)
+	room, err := roomRegistry.GetRoom(ctx, "main")
+	assert.NoError(t, err)
+
+	groupHold, err := room.HoldSeats(ctx, []manager.Seat{{GroupID: "abc", Coordinate: manager.Coordinate{0, 0}}})
+	assert.NoError(t, err)
+	rowHold, err := room.HoldSeats(ctx, []manager.Seat{
+		{GroupID: "xyz", Coordinate: manager.Coordinate{2, 3}},
+		{GroupID: "xyz", Coordinate: manager.Coordinate{3, 3}},
+	})
+	assert.NoError(t, err)
+
+	_, err = roomRegistry.DeleteGroup(ctx, "abc", true)
+	assert.NoError(t, err)
+	_, err = room.Reconfigure(ctx, config.Room{NumRows: 3, NumCols: 4, MinDistance: 1}, true)
+	assert.NoError(t, err)
+
+	entries, _, err := auditLog.Query(ctx, manager.AuditQuery{Operation: manager.AuditOperationRelease})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, groupHold.ID, entries[0].HoldID)
+	assert.Equal(t, "main", entries[0].RoomID)
+	assert.Equal(t, manager.Seat{GroupID: "abc", Coordinate: manager.Coordinate{0, 0}}, entries[0].Seats[0])
+	// only the seats the reconfiguration removed are released
+	assert.Equal(t, rowHold.ID, entries[1].HoldID)
+	assert.Equal(t, 1, len(entries[1].Seats))
+	assert.Equal(t, manager.Seat{GroupID: "xyz", Coordinate: manager.Coordinate{3, 3}}, entries[1].Seats[0])
+}
+
+func TestDefaultRoomManager_AuditTransaction(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	cfg := config.Room{ID: "main", NumRows: 4, NumCols: 4, MinDistance: 1}
+	groupManager := manager.NewGroupManager([]string{"abc"})
+	auditLog := manager.NewMemoryAuditLog()
+	room := manager.NewRoomManager(slog.Default(), &cfg, &config.Hold{}, groupManager, manager.NewMemoryRoomStore(), nil, auditLog)
+
+	seat := manager.Seat{GroupID: "abc", Coordinate: manager.Coordinate{0, 0}}
+	mutation, err := room.ApplyTransaction(ctx, []manager.Operation{{Type: manager.OperationReserve, Seat: seat}})
+	assert.NoError(t, err)
+	_, err = room.ApplyTransaction(ctx, []manager.Operation{{Type: manager.OperationCancel, Seat: seat}})
+	assert.NoError(t, err)
+
+	entries, _, err := auditLog.Query(ctx, manager.AuditQuery{Operation: manager.AuditOperationTransaction})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, mutation.Reserved[0].BookingID, entries[0].BookingID)
+	// a transaction that only cancels points at no booking
+	assert.Equal(t, "", entries[1].BookingID)
+}
@@ -0,0 +1,217 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/namlh/vulcanLabsOA/config"
+)
+
+const (
+	ErrRoomNotFound  = Error("room not found")
+	ErrGroupHasSeats = Error("group still has seats")
+)
+
+const defaultSweepInterval = time.Second
+
+type RoomRegistry interface {
+	ListRooms(ctx context.Context) []config.Room
+	GetRoom(ctx context.Context, roomID string) (RoomManager, error)
+	Restore(ctx context.Context) error
+	SweepHolds(ctx context.Context, interval time.Duration)
+	ListGroupReservations(ctx context.Context, groupID string) map[string][]Reservation
+	DeleteGroup(ctx context.Context, groupID string, cascade bool) (int, error)
+	Close() error
+}
+
+type DefaultRoomRegistry struct {
+	roomIDs      []string
+	rooms        map[string]*DefaultRoomManager
+	groupManager GroupManager
+}
+
+func NewRoomRegistry(
+	logger *slog.Logger,
+	cfgs []config.Room,
+	storeCfg *config.Store,
+	holdCfg *config.Hold,
+	groupManager GroupManager,
+	events EventPublisher,
+	audit AuditLog,
+) (RoomRegistry, error) {
+	registry := &DefaultRoomRegistry{
+		roomIDs:      make([]string, 0, len(cfgs)),
+		rooms:        make(map[string]*DefaultRoomManager, len(cfgs)),
+		groupManager: groupManager,
+	}
+
+	for i := range cfgs {
+		cfg := &cfgs[i]
+		if cfg.ID == "" {
+			return nil, fmt.Errorf("room at index %d: id must not be empty", i)
+		}
+		if _, ok := registry.rooms[cfg.ID]; ok {
+			return nil, fmt.Errorf("room %s: duplicated id", cfg.ID)
+		}
+		if cfg.NumRows <= 0 || cfg.NumCols <= 0 {
+			return nil, fmt.Errorf("room %s: num_rows and num_cols must be greater than 0", cfg.ID)
+		}
+		metric, err := NewDistanceMetric(cfg.DistanceMetric)
+		if err != nil {
+			return nil, fmt.Errorf("room %s: %w", cfg.ID, err)
+		}
+		cfg.DistanceMetric = metric.Name()
+		if _, err := newSeatMap(cfg); err != nil {
+			return nil, fmt.Errorf("room %s: %w", cfg.ID, err)
+		}
+		if _, err := NewSeparationPolicy(cfg); err != nil {
+			return nil, fmt.Errorf("room %s: %w", cfg.ID, err)
+		}
+
+		roomLogger := logger.With("room_id", cfg.ID)
+		store, err := NewRoomStore(roomLogger, storeCfg, cfg.ID)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("room %s: new store: %w", cfg.ID, err), registry.Close())
+		}
+
+		registry.roomIDs = append(registry.roomIDs, cfg.ID)
+		registry.rooms[cfg.ID] = newRoomManager(roomLogger, cfg, holdCfg, groupManager, store, events, audit)
+	}
+
+	return registry, nil
+}
+
+func (r *DefaultRoomRegistry) ListRooms(ctx context.Context) []config.Room {
+	rooms := make([]config.Room, len(r.roomIDs))
+	for i, roomID := range r.roomIDs {
+		rooms[i] = r.rooms[roomID].Config(ctx)
+	}
+
+	return rooms
+}
+
+func (r *DefaultRoomRegistry) GetRoom(_ context.Context, roomID string) (RoomManager, error) {
+	room, ok := r.rooms[roomID]
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+
+	return room, nil
+}
+
+func (r *DefaultRoomRegistry) Restore(ctx context.Context) error {
+	for _, roomID := range r.roomIDs {
+		if err := r.rooms[roomID].Restore(ctx); err != nil {
+			return fmt.Errorf("restore room %s: %w", roomID, err)
+		}
+	}
+
+	return nil
+}
+
+// SweepHolds releases expired holds of every room each interval until ctx is done.
+func (r *DefaultRoomRegistry) SweepHolds(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultSweepInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, roomID := range r.roomIDs {
+				r.rooms[roomID].ReleaseExpiredHolds(ctx)
+			}
+		}
+	}
+}
+
+// ListGroupReservations returns the seats reserved by groupID keyed by room id.
+func (r *DefaultRoomRegistry) ListGroupReservations(ctx context.Context, groupID string) map[string][]Reservation {
+	reservations := make(map[string][]Reservation, len(r.roomIDs))
+	for _, roomID := range r.roomIDs {
+		reservations[roomID] = r.rooms[roomID].ListReservations(ctx, groupID)
+	}
+
+	return reservations
+}
+
+// GroupReleaseError reports a cascading delete that failed to release the
+// group in RoomID after releasing it in the Released rooms. The group is
+// not deleted, so the delete can be retried.
+type GroupReleaseError struct {
+	RoomID   string
+	Released []string
+	Err      error
+}
+
+func (e GroupReleaseError) Error() string {
+	return fmt.Sprintf("release group in room %s after rooms %v: %s", e.RoomID, e.Released, e.Err)
+}
+
+func (e GroupReleaseError) Unwrap() error {
+	return e.Err
+}
+
+// DeleteGroup deletes groupID when it has no seat left in any room, or
+// after releasing its seats in every room when cascade is set. Otherwise it
+// returns ErrGroupHasSeats with the number of seats the group still has.
+// Waitlist entries do not hold seats, so they never block the delete and
+// are dropped in both cases.
+//
+// Every room is locked for the whole operation, so the group cannot take a
+// seat between the check and the delete, and the group is only deleted once
+// its seats are released.
+func (r *DefaultRoomRegistry) DeleteGroup(ctx context.Context, groupID string, cascade bool) (int, error) {
+	// rooms are always locked in the same order, before the group manager
+	for _, roomID := range r.roomIDs {
+		r.rooms[roomID].mu.Lock()
+		defer r.rooms[roomID].unlock()
+	}
+
+	if !r.groupManager.HasGroupID(ctx, groupID) {
+		return 0, ErrGroupIdNotFound
+	}
+
+	if cascade {
+		// each room commits its own release, so a failure leaves the earlier
+		// rooms released and the group kept for a retry
+		for i, roomID := range r.roomIDs {
+			if err := r.rooms[roomID].releaseGroup(ctx, groupID); err != nil {
+				return 0, GroupReleaseError{RoomID: roomID, Released: slices.Clone(r.roomIDs[:i]), Err: err}
+			}
+		}
+	} else {
+		count := 0
+		for _, roomID := range r.roomIDs {
+			count += r.rooms[roomID].countGroupSeats(groupID)
+		}
+		if count > 0 {
+			return count, ErrGroupHasSeats
+		}
+		for _, roomID := range r.roomIDs {
+			r.rooms[roomID].dropWaitlist(groupID)
+		}
+	}
+
+	return 0, r.groupManager.DeleteGroup(ctx, groupID)
+}
+
+func (r *DefaultRoomRegistry) Close() error {
+	var err error
+	for _, roomID := range r.roomIDs {
+		if closeErr := r.rooms[roomID].Close(); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("close room %s: %w", roomID, closeErr))
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,125 @@
+package manager
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/namlh/vulcanLabsOA/config"
+)
+
+const (
+	StoreTypeMemory = "memory"
+	StoreTypeFile   = "file"
+)
+
+// RoomStore durably records the reserved seats of a single room and the
+// configuration it was switched to at runtime.
+// Append must only return once the mutation survives a restart.
+type RoomStore interface {
+	Load(ctx context.Context) (Snapshot, error)
+	Append(ctx context.Context, mutation Mutation) error
+	Close() error
+}
+
+type Snapshot struct {
+	Seats []Reservation `json:"seats"`
+	// Room is the last configuration the room was switched to at runtime.
+	Room *config.Room `json:"room,omitempty"`
+}
+
+type Mutation struct {
+	Reserved  []Reservation `json:"reserved,omitempty"`
+	Cancelled []Coordinate  `json:"cancelled,omitempty"`
+	// Room switches the room to a new configuration.
+	Room *config.Room `json:"room,omitempty"`
+}
+
+func NewRoomStore(logger *slog.Logger, cfg *config.Store, roomID string) (RoomStore, error) {
+	switch cfg.Type {
+	case "", StoreTypeMemory:
+		return NewMemoryRoomStore(), nil
+	case StoreTypeFile:
+		return NewFileRoomStore(logger, cfg.Dir, roomID, cfg.SnapshotEvery)
+	default:
+		return nil, fmt.Errorf("unknown store type %q", cfg.Type)
+	}
+}
+
+type MemoryRoomStore struct {
+	mu    *sync.Mutex
+	seats *seatTable
+}
+
+func NewMemoryRoomStore() *MemoryRoomStore {
+	return &MemoryRoomStore{
+		mu:    new(sync.Mutex),
+		seats: newSeatTable(),
+	}
+}
+
+func (s *MemoryRoomStore) Load(_ context.Context) (Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.seats.snapshot(), nil
+}
+
+func (s *MemoryRoomStore) Append(_ context.Context, mutation Mutation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seats.apply(mutation)
+
+	return nil
+}
+
+func (s *MemoryRoomStore) Close() error {
+	return nil
+}
+
+// seatTable is the materialized state a store rebuilds from its mutations.
+// Seats are keyed by coordinate rather than index so that they do not
+// depend on the room dimensions.
+type seatTable struct {
+	seats map[Coordinate]Reservation
+	room  *config.Room
+}
+
+func newSeatTable() *seatTable {
+	return &seatTable{seats: make(map[Coordinate]Reservation)}
+}
+
+func (t *seatTable) apply(mutation Mutation) {
+	for _, coord := range mutation.Cancelled {
+		delete(t.seats, coord)
+	}
+	for _, reservation := range mutation.Reserved {
+		t.seats[reservation.Coordinate] = reservation
+	}
+	if mutation.Room != nil {
+		t.room = mutation.Room
+	}
+}
+
+func (t *seatTable) load(snapshot Snapshot) {
+	for _, reservation := range snapshot.Seats {
+		t.seats[reservation.Coordinate] = reservation
+	}
+	t.room = snapshot.Room
+}
+
+func (t *seatTable) snapshot() Snapshot {
+	seats := make([]Reservation, 0, len(t.seats))
+	for _, reservation := range t.seats {
+		seats = append(seats, reservation)
+	}
+	slices.SortFunc(seats, func(a, b Reservation) int {
+		return cmp.Or(cmp.Compare(a.Row(), b.Row()), cmp.Compare(a.Col(), b.Col()))
+	})
+
+	return Snapshot{Seats: seats, Room: t.room}
+}
@@ -0,0 +1,93 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+)
+
+type SeatErrorCode int
+
+const (
+	SeatErrorCodeOutOfBound SeatErrorCode = iota + 1
+	SeatErrorCodeSeatTaken
+	SeatErrorCodeInvalidDistance
+	SeatErrorCodeGroupIDNotFound
+	SeatErrorCodeNotReserved
+	SeatErrorCodeDuplicatedPosition
+	SeatErrorCodeNotInBooking
+	SeatErrorCodeNotASeat
+)
+
+// String returns the machine-readable name of the code.
+func (c SeatErrorCode) String() string {
+	switch c {
+	case SeatErrorCodeOutOfBound:
+		return "out_of_bound"
+	case SeatErrorCodeSeatTaken:
+		return "seat_taken"
+	case SeatErrorCodeInvalidDistance:
+		return "invalid_distance"
+	case SeatErrorCodeGroupIDNotFound:
+		return "group_id_not_found"
+	case SeatErrorCodeNotReserved:
+		return "not_reserved"
+	case SeatErrorCodeDuplicatedPosition:
+		return "duplicated_position"
+	case SeatErrorCodeNotInBooking:
+		return "not_in_booking"
+	case SeatErrorCodeNotASeat:
+		return "not_a_seat"
+	}
+
+	return ""
+}
+
+type SeatError struct {
+	seat  Seat
+	Code  SeatErrorCode
+	index int
+}
+
+func (e SeatError) Seat() Seat {
+	return e.seat
+}
+
+// Index is the position of the offending seat in the request.
+func (e SeatError) Index() int {
+	return e.index
+}
+
+func (e SeatError) Error() string {
+	switch e.Code {
+	case SeatErrorCodeOutOfBound:
+		return fmt.Sprintf("position [%d,%d] at index %d out of bound", e.seat.Row(), e.seat.Col(), e.index)
+	case SeatErrorCodeSeatTaken:
+		return fmt.Sprintf("position [%d,%d] at index %d has already been taken", e.seat.Row(), e.seat.Col(), e.index)
+	case SeatErrorCodeInvalidDistance:
+		return fmt.Sprintf("position [%d,%d] at index %d violate min distance constraint", e.seat.Row(), e.seat.Col(), e.index)
+	case SeatErrorCodeGroupIDNotFound:
+		return fmt.Sprintf("group_id %s at index %d not found", e.seat.GroupID, e.index)
+	case SeatErrorCodeNotReserved:
+		return fmt.Sprintf("position [%d,%d] at index %d did not get reserved", e.seat.Row(), e.seat.Col(), e.index)
+	case SeatErrorCodeDuplicatedPosition:
+		return fmt.Sprintf("position [%d,%d] at index %d is duplicated", e.seat.Row(), e.seat.Col(), e.index)
+	case SeatErrorCodeNotInBooking:
+		return fmt.Sprintf("position [%d,%d] at index %d does not belong to the booking", e.seat.Row(), e.seat.Col(), e.index)
+	case SeatErrorCodeNotASeat:
+		return fmt.Sprintf("position [%d,%d] at index %d is not a seat", e.seat.Row(), e.seat.Col(), e.index)
+	}
+
+	return ""
+}
+
+// SeatErrors holds every violation found in a request, ordered by index.
+type SeatErrors []SeatError
+
+func (e SeatErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,213 @@
+package manager
+
+// seatIndex counts, for every cell and every distance of the separation
+// policy, the occupied seats of each group that are closer than that
+// distance. A cell is blocked for a group as soon as a seat of another group
+// is counted on it at the distance the policy sets between the two groups,
+// so availability is answered without scanning every occupied seat.
+//
+// Adding or removing a seat only touches the cells around it that are
+// closer than the largest distance under the room's metric.
+//
+// The index also keeps the seats apart by whether any occupied seat is
+// closer than the largest distance, so that listing the available seats
+// only goes through the isolated seats and the seats around the occupied
+// ones rather than through the whole grid.
+type seatIndex struct {
+	numRows int
+	numCols int
+	metric  DistanceMetric
+	policy  SeparationPolicy
+	// seats lists the seats of the room, the isolated ones first, and
+	// position gives the place of every seat in it
+	seats       []int64
+	position    []int32
+	numIsolated int
+	// uniform is set when every pair of groups has the same distance, in
+	// which case there is a single level
+	uniform bool
+	// levels are sorted by increasing distance
+	levels []seatLevel
+}
+
+type seatLevel struct {
+	minDistance int
+	total       []int32
+	byGroup     []map[string]int32
+}
+
+func newSeatIndex(numRows, numCols int, layout seatMap, policy SeparationPolicy, metric DistanceMetric) *seatIndex {
+	size := numRows * numCols
+	seats := make([]int64, 0, layout.numSeats(numRows))
+	position := make([]int32, size)
+	for idx := range int64(size) {
+		position[idx] = -1
+		if layout.isSeat(idx) {
+			position[idx] = int32(len(seats))
+			seats = append(seats, idx)
+		}
+	}
+
+	distances := policy.distances()
+	levels := make([]seatLevel, len(distances))
+	for i, distance := range distances {
+		levels[i] = seatLevel{
+			minDistance: distance,
+			total:       make([]int32, size),
+			byGroup:     make([]map[string]int32, size),
+		}
+	}
+
+	return &seatIndex{
+		numRows:     numRows,
+		numCols:     numCols,
+		metric:      metric,
+		policy:      policy,
+		seats:       seats,
+		position:    position,
+		numIsolated: len(seats),
+		uniform:     policy.uniform(),
+		levels:      levels,
+	}
+}
+
+func (x *seatIndex) add(coord Coordinate, groupID string) {
+	x.update(coord, groupID, 1)
+}
+
+func (x *seatIndex) remove(coord Coordinate, groupID string) {
+	x.update(coord, groupID, -1)
+}
+
+// isolated reports whether no occupied seat, including one on idx itself,
+// is closer to idx than the largest distance.
+func (x *seatIndex) isolated(idx int64) bool {
+	return x.levels[len(x.levels)-1].total[idx] == 0
+}
+
+// isolatedSeats returns the isolated seats, in no particular order. The
+// slice is only valid until the index changes.
+func (x *seatIndex) isolatedSeats() []int64 {
+	return x.seats[:x.numIsolated]
+}
+
+// crowdedSeats returns the seats that are not isolated, in no particular
+// order. The slice is only valid until the index changes.
+func (x *seatIndex) crowdedSeats() []int64 {
+	return x.seats[x.numIsolated:]
+}
+
+// sortRowMajor sorts indexes in row-major order. It counts them by column
+// then by row, so that its cost grows with the number of indexes and the
+// size of a side rather than with the area of the room.
+func (x *seatIndex) sortRowMajor(indexes []int64) []int64 {
+	byCol := countingSort(indexes, x.numCols, func(idx int64) int { return int(idx % int64(x.numCols)) })
+	return countingSort(byCol, x.numRows, func(idx int64) int { return int(idx / int64(x.numCols)) })
+}
+
+// countingSort stably sorts indexes by key, which is in [0, n).
+func countingSort(indexes []int64, n int, key func(idx int64) int) []int64 {
+	starts := make([]int, n+1)
+	for _, idx := range indexes {
+		starts[key(idx)+1]++
+	}
+	for i := range n {
+		starts[i+1] += starts[i]
+	}
+
+	sorted := make([]int64, len(indexes))
+	for _, idx := range indexes {
+		k := key(idx)
+		sorted[starts[k]] = idx
+		starts[k]++
+	}
+
+	return sorted
+}
+
+// blocked reports whether a seat of another group than groupID is closer
+// to idx than the distance between the two groups.
+func (x *seatIndex) blocked(idx int64, groupID string) bool {
+	if x.uniform {
+		level := &x.levels[0]
+		return level.total[idx]-level.byGroup[idx][groupID] > 0
+	}
+
+	for i := range x.levels {
+		level := &x.levels[i]
+		if level.total[idx]-level.byGroup[idx][groupID] == 0 {
+			continue
+		}
+		for other := range level.byGroup[idx] {
+			if other != groupID && max(x.policy.MinDistance(groupID, other), 1) == level.minDistance {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (x *seatIndex) update(coord Coordinate, groupID string, delta int32) {
+	for i := range x.levels {
+		x.levels[i].update(x, coord, groupID, delta)
+	}
+}
+
+// setIsolated moves the seat idx to the isolated or to the crowded seats
+// by swapping it with the first seat past the isolated ones or with the
+// last isolated seat.
+func (x *seatIndex) setIsolated(idx int64, isolated bool) {
+	at := x.position[idx]
+	if at < 0 {
+		return
+	}
+
+	var to int32
+	if isolated {
+		to = int32(x.numIsolated)
+		x.numIsolated++
+	} else {
+		x.numIsolated--
+		to = int32(x.numIsolated)
+	}
+
+	other := x.seats[to]
+	x.seats[at], x.seats[to] = other, idx
+	x.position[other], x.position[idx] = at, to
+}
+
+func (l *seatLevel) update(x *seatIndex, coord Coordinate, groupID string, delta int32) {
+	reachRows, reachCols := x.metric.Reach(l.minDistance)
+	outermost := l == &x.levels[len(x.levels)-1]
+
+	for row := max(coord[0]-reachRows, 0); row <= min(coord[0]+reachRows, x.numRows-1); row++ {
+		for col := max(coord[1]-reachCols, 0); col <= min(coord[1]+reachCols, x.numCols-1); col++ {
+			cell := Coordinate{row, col}
+			if !x.metric.Closer(coord, cell, l.minDistance) {
+				continue
+			}
+
+			idx := cell.AsIndex(x.numCols)
+			l.total[idx] += delta
+			if outermost && l.total[idx] == delta {
+				x.setIsolated(idx, false)
+			} else if outermost && l.total[idx] == 0 {
+				x.setIsolated(idx, true)
+			}
+
+			counts := l.byGroup[idx]
+			if counts == nil {
+				counts = make(map[string]int32, 1)
+				l.byGroup[idx] = counts
+			}
+			counts[groupID] += delta
+			if counts[groupID] == 0 {
+				delete(counts, groupID)
+			}
+			if len(counts) == 0 {
+				l.byGroup[idx] = nil
+			}
+		}
+	}
+}
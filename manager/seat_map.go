@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/namlh/vulcanLabsOA/config"
+)
+
+const (
+	CellSeat  = 'S'
+	CellAisle = '_'
+	CellVoid  = 'X'
+)
+
+// seatMap tells seats apart from aisles and voids. Distances are still
+// measured on the physical grid, so an aisle between two seats counts.
+type seatMap struct {
+	numCols int
+	cells   []byte
+}
+
+func newSeatMap(cfg *config.Room) (seatMap, error) {
+	if len(cfg.Layout) == 0 {
+		return seatMap{numCols: cfg.NumCols}, nil
+	}
+
+	if len(cfg.Layout) != cfg.NumRows {
+		return seatMap{}, fmt.Errorf("layout has %d rows, expected %d", len(cfg.Layout), cfg.NumRows)
+	}
+
+	cells := make([]byte, 0, cfg.NumRows*cfg.NumCols)
+	for i, row := range cfg.Layout {
+		if len(row) != cfg.NumCols {
+			return seatMap{}, fmt.Errorf("layout row %d has %d columns, expected %d", i, len(row), cfg.NumCols)
+		}
+
+		for j := range len(row) {
+			switch row[j] {
+			case CellSeat, CellAisle, CellVoid:
+			default:
+				return seatMap{}, fmt.Errorf("layout row %d column %d: unknown cell %q", i, j, row[j])
+			}
+		}
+		cells = append(cells, row...)
+	}
+
+	return seatMap{numCols: cfg.NumCols, cells: cells}, nil
+}
+
+// numSeats counts the seats of a room with numRows rows.
+func (s seatMap) numSeats(numRows int) int {
+	if s.cells == nil {
+		return numRows * s.numCols
+	}
+
+	return bytes.Count(s.cells, []byte{CellSeat})
+}
+
+func (s seatMap) isSeat(idx int64) bool {
+	return s.cells == nil || s.cells[idx] == CellSeat
+}
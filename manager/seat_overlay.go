@@ -0,0 +1,125 @@
+package manager
+
+import (
+	"cmp"
+	"slices"
+)
+
+// seatOverlay stages changes to the reserved seats of a room so that a batch
+// can be validated against its final state before anything is committed.
+// The caller must hold m.mu for the whole lifetime of the overlay.
+type seatOverlay struct {
+	m      *DefaultRoomManager
+	staged map[int64]stagedSeat
+}
+
+type stagedSeat struct {
+	reservation Reservation
+	reserved    bool
+	// index is the position in the request of the operation that staged the seat
+	index int
+}
+
+func newSeatOverlay(m *DefaultRoomManager) *seatOverlay {
+	return &seatOverlay{
+		m:      m,
+		staged: make(map[int64]stagedSeat),
+	}
+}
+
+func (o *seatOverlay) reservation(idx int64) (Reservation, bool) {
+	if staged, ok := o.staged[idx]; ok {
+		return staged.reservation, staged.reserved
+	}
+
+	reservation, ok := o.m.reservedSeat[idx]
+
+	return reservation, ok
+}
+
+// occupied reports whether the seat is reserved or held once the staged
+// changes are applied.
+func (o *seatOverlay) occupied(idx int64) bool {
+	if staged, ok := o.staged[idx]; ok {
+		return staged.reserved
+	}
+
+	return o.m.isOccupied(idx)
+}
+
+func (o *seatOverlay) reserve(reservation Reservation, index int) {
+	o.staged[reservation.AsIndex(o.m.cfg.NumCols)] = stagedSeat{reservation: reservation, reserved: true, index: index}
+}
+
+func (o *seatOverlay) cancel(coord Coordinate, index int) {
+	o.staged[coord.AsIndex(o.m.cfg.NumCols)] = stagedSeat{index: index}
+}
+
+// mutation returns the difference between the staged and the committed seats.
+func (o *seatOverlay) mutation() Mutation {
+	idxs := make([]int64, 0, len(o.staged))
+	for idx := range o.staged {
+		idxs = append(idxs, idx)
+	}
+	slices.Sort(idxs)
+
+	var mutation Mutation
+	for _, idx := range idxs {
+		staged := o.staged[idx]
+		committed, ok := o.m.reservedSeat[idx]
+		if ok && staged.reserved && committed == staged.reservation {
+			continue
+		}
+		if ok {
+			mutation.Cancelled = append(mutation.Cancelled, committed.Coordinate)
+		}
+		if staged.reserved {
+			mutation.Reserved = append(mutation.Reserved, staged.reservation)
+		}
+	}
+
+	return mutation
+}
+
+// violations checks the distance of every staged reservation against the
+// room once the staged cancellations are applied. Like validateSeats, the
+// reservations are checked in request order against the valid ones before
+// them.
+func (o *seatOverlay) violations() SeatErrors {
+	mutation := o.mutation()
+	slices.SortStableFunc(mutation.Reserved, func(a, b Reservation) int {
+		return cmp.Compare(o.stagedIndex(a.Coordinate), o.stagedIndex(b.Coordinate))
+	})
+
+	cancelled := make([]Reservation, len(mutation.Cancelled))
+	for i, coord := range mutation.Cancelled {
+		cancelled[i] = o.m.reservedSeat[coord.AsIndex(o.m.cfg.NumCols)]
+		o.m.index.remove(coord, cancelled[i].GroupID)
+	}
+
+	var errs SeatErrors
+	var indexed []Reservation
+	defer func() {
+		for _, reservation := range indexed {
+			o.m.index.remove(reservation.Coordinate, reservation.GroupID)
+		}
+		for _, reservation := range cancelled {
+			o.m.index.add(reservation.Coordinate, reservation.GroupID)
+		}
+	}()
+
+	for _, reservation := range mutation.Reserved {
+		if o.m.index.blocked(reservation.AsIndex(o.m.cfg.NumCols), reservation.GroupID) {
+			errs = append(errs, SeatError{reservation.Seat, SeatErrorCodeInvalidDistance, o.stagedIndex(reservation.Coordinate)})
+			continue
+		}
+		o.m.index.add(reservation.Coordinate, reservation.GroupID)
+		indexed = append(indexed, reservation)
+	}
+
+	return errs
+}
+
+func (o *seatOverlay) stagedIndex(coord Coordinate) int {
+	return o.staged[coord.AsIndex(o.m.cfg.NumCols)].index
+}
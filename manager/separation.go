@@ -0,0 +1,107 @@
+package manager
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/namlh/vulcanLabsOA/config"
+)
+
+// SeparationPolicy decides how far apart the seats of two groups must be:
+// seats of a same group have no minimum distance, a separation pair sets
+// the distance of its two groups, an affinity cluster the distance between
+// its groups, and the room's minimum distance applies otherwise.
+type SeparationPolicy struct {
+	minDistance int
+	pairs       map[[2]string]int
+	clusters    map[string]int
+	// clusterDistances holds the minimum distance of each cluster
+	clusterDistances []int
+}
+
+func NewSeparationPolicy(cfg *config.Room) (SeparationPolicy, error) {
+	policy := SeparationPolicy{
+		minDistance: cfg.MinDistance,
+		pairs:       make(map[[2]string]int, len(cfg.SeparationPairs)),
+		clusters:    make(map[string]int),
+	}
+
+	for i, pair := range cfg.SeparationPairs {
+		if pair.Groups[0] == "" || pair.Groups[1] == "" || pair.Groups[0] == pair.Groups[1] {
+			return SeparationPolicy{}, fmt.Errorf("separation pair at index %d: groups must be two different group ids", i)
+		}
+		if pair.MinDistance < 0 {
+			return SeparationPolicy{}, fmt.Errorf("separation pair at index %d: min_distance must not be negative", i)
+		}
+
+		key := pairKey(pair.Groups[0], pair.Groups[1])
+		if _, ok := policy.pairs[key]; ok {
+			return SeparationPolicy{}, fmt.Errorf("separation pair at index %d: duplicated pair %v", i, key)
+		}
+		policy.pairs[key] = pair.MinDistance
+	}
+
+	for i, cluster := range cfg.AffinityClusters {
+		if cluster.MinDistance < 0 {
+			return SeparationPolicy{}, fmt.Errorf("affinity cluster at index %d: min_distance must not be negative", i)
+		}
+
+		for _, groupID := range cluster.Groups {
+			if groupID == "" {
+				return SeparationPolicy{}, fmt.Errorf("affinity cluster at index %d: group id must not be empty", i)
+			}
+			if _, ok := policy.clusters[groupID]; ok {
+				return SeparationPolicy{}, fmt.Errorf("affinity cluster at index %d: group %s is already in a cluster", i, groupID)
+			}
+			policy.clusters[groupID] = i
+		}
+		policy.clusterDistances = append(policy.clusterDistances, cluster.MinDistance)
+	}
+
+	return policy, nil
+}
+
+// MinDistance returns how far apart the seats of groups a and b must be.
+func (p SeparationPolicy) MinDistance(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if distance, ok := p.pairs[pairKey(a, b)]; ok {
+		return distance
+	}
+	if clusterA, ok := p.clusters[a]; ok {
+		if clusterB, ok := p.clusters[b]; ok && clusterA == clusterB {
+			return p.clusterDistances[clusterA]
+		}
+	}
+
+	return p.minDistance
+}
+
+// uniform reports whether every pair of groups has the room's distance.
+func (p SeparationPolicy) uniform() bool {
+	return len(p.pairs) == 0 && len(p.clusters) == 0
+}
+
+// distances returns every distance of the policy in increasing order.
+// Distances below 1 are raised to 1, as seats are always at least 1 apart.
+func (p SeparationPolicy) distances() []int {
+	distances := []int{max(p.minDistance, 1)}
+	for _, distance := range p.pairs {
+		distances = append(distances, max(distance, 1))
+	}
+	for _, distance := range p.clusterDistances {
+		distances = append(distances, max(distance, 1))
+	}
+	slices.Sort(distances)
+
+	return slices.Compact(distances)
+}
+
+func pairKey(a, b string) [2]string {
+	if a > b {
+		a, b = b, a
+	}
+
+	return [2]string{a, b}
+}
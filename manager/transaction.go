@@ -0,0 +1,92 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/namlh/vulcanLabsOA/util/idutil"
+)
+
+const (
+	OperationReserve = "reserve"
+	OperationCancel  = "cancel"
+)
+
+// Operation reserves or cancels a single seat. GroupID is ignored when
+// cancelling.
+type Operation struct {
+	Type string `json:"type"`
+	Seat
+}
+
+// ApplyTransaction applies the operations in order as a single unit.
+// Distance rules are checked against the room once every operation is
+// applied. On failure the error of the first failing operation is returned
+// and nothing changes. The seats reserved by the transaction share a new
+// booking.
+func (m *DefaultRoomManager) ApplyTransaction(ctx context.Context, ops []Operation) (_ Mutation, err error) {
+	m.mu.Lock()
+	defer m.unlock()
+	defer m.reportVersion(ctx)
+	defer m.fillWaitlistOnSuccess(ctx, &err)
+
+	bookingID := idutil.New()
+	entry := AuditEntry{Operation: AuditOperationTransaction, Seats: make([]Seat, len(ops))}
+	for i, op := range ops {
+		entry.Seats[i] = op.Seat
+		if op.Type == OperationCancel {
+			entry.Seats[i] = m.reservedSeats([]Coordinate{op.Coordinate})[0]
+		}
+	}
+	defer func() {
+		m.record(ctx, entry, err)
+	}()
+
+	if err := m.checkVersion(ctx); err != nil {
+		return Mutation{}, err
+	}
+
+	overlay := newSeatOverlay(m)
+	for i, op := range ops {
+		if !m.inBound(op.Coordinate) {
+			return Mutation{}, SeatError{op.Seat, SeatErrorCodeOutOfBound, i}
+		}
+		idx := op.Coordinate.AsIndex(m.cfg.NumCols)
+
+		switch op.Type {
+		case OperationReserve:
+			if !m.groupManager.HasGroupID(ctx, op.GroupID) {
+				return Mutation{}, SeatError{op.Seat, SeatErrorCodeGroupIDNotFound, i}
+			}
+			if !m.seatMap.isSeat(idx) {
+				return Mutation{}, SeatError{op.Seat, SeatErrorCodeNotASeat, i}
+			}
+			if overlay.occupied(idx) {
+				return Mutation{}, SeatError{op.Seat, SeatErrorCodeSeatTaken, i}
+			}
+			overlay.reserve(Reservation{Seat: op.Seat, BookingID: bookingID}, i)
+		case OperationCancel:
+			if _, ok := overlay.reservation(idx); !ok {
+				return Mutation{}, SeatError{op.Seat, SeatErrorCodeNotReserved, i}
+			}
+			overlay.cancel(op.Coordinate, i)
+		default:
+			return Mutation{}, fmt.Errorf("unknown operation %q at index %d", op.Type, i)
+		}
+	}
+
+	if errs := overlay.violations(); len(errs) > 0 {
+		return Mutation{}, errs[0]
+	}
+
+	mutation := overlay.mutation()
+	if err = m.commit(ctx, mutation); err != nil {
+		return Mutation{}, err
+	}
+	// a transaction that only cancels creates no booking
+	if len(mutation.Reserved) > 0 {
+		entry.BookingID = bookingID
+	}
+
+	return mutation, nil
+}
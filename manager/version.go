@@ -0,0 +1,65 @@
+package manager
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/namlh/vulcanLabsOA/consts/ctxkey"
+)
+
+const (
+	ErrVersionMismatch = Error("room version mismatch")
+)
+
+// WithExpectedVersions makes mutations of a room fail with
+// ErrVersionMismatch unless the room is still at one of versions.
+func WithExpectedVersions(ctx context.Context, versions []uint64) context.Context {
+	return context.WithValue(ctx, ctxkey.ExpectedVersions{}, versions)
+}
+
+// WithVersionReport makes mutations of a room store in *version the
+// version they leave the room at, once the waitlist entries they serve are
+// reserved as well.
+func WithVersionReport(ctx context.Context, version *uint64) context.Context {
+	return context.WithValue(ctx, ctxkey.ReportedVersion{}, version)
+}
+
+// versionShift leaves room for about a million versions per second between
+// two restarts, while keeping versions below 2^53, that is up to 2^33
+// seconds after the Unix epoch in 2242, so that JavaScript clients read
+// them without losing precision.
+const versionShift = 20
+
+// initialVersion seeds the version of a room created at now.
+func initialVersion(now time.Time) uint64 {
+	return uint64(now.Unix()) << versionShift
+}
+
+// Version returns the current version of the room, which changes whenever
+// a seat is reserved, cancelled, held or released.
+func (m *DefaultRoomManager) Version(_ context.Context) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.version
+}
+
+// checkVersion enforces the versions expected by ctx. The caller must hold m.mu.
+func (m *DefaultRoomManager) checkVersion(ctx context.Context) error {
+	versions, ok := ctx.Value(ctxkey.ExpectedVersions{}).([]uint64)
+	if !ok || slices.Contains(versions, m.version) {
+		return nil
+	}
+
+	return ErrVersionMismatch
+}
+
+// reportVersion stores the version of the room where ctx asks for it.
+// Mutations defer it right after locking m.mu, so that it runs once every
+// other change is applied. The caller must hold m.mu.
+func (m *DefaultRoomManager) reportVersion(ctx context.Context) {
+	if version, ok := ctx.Value(ctxkey.ReportedVersion{}).(*uint64); ok {
+		*version = m.version
+	}
+}
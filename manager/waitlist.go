@@ -0,0 +1,206 @@
+package manager
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/namlh/vulcanLabsOA/consts/ctxkey"
+	"github.com/namlh/vulcanLabsOA/util/idutil"
+)
+
+const (
+	ErrWaitlistEntryNotFound = Error("waitlist entry not found")
+)
+
+// waitlistActor is the audit actor of the reservations made for waitlist
+// entries.
+const waitlistActor = "waitlist"
+
+// WaitlistEntry waits for Count seats anywhere in the room, or for exactly
+// Seats, for a group. Like holds, entries only live in memory.
+type WaitlistEntry struct {
+	ID        string       `json:"waitlist_id"`
+	GroupID   string       `json:"group_id"`
+	Count     int          `json:"count,omitempty"`
+	Seats     []Coordinate `json:"seats,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	// BookingID is set once the entry is fulfilled.
+	BookingID string `json:"booking_id,omitempty"`
+}
+
+// JoinWaitlist appends an entry for Count seats or for Seats to the
+// waitlist. The entry is fulfilled right away when it can be, in which case
+// the returned entry has a booking id.
+func (m *DefaultRoomManager) JoinWaitlist(ctx context.Context, groupID string, count int, seats []Coordinate) (WaitlistEntry, error) {
+	m.mu.Lock()
+	defer m.unlock()
+	defer m.reportVersion(ctx)
+
+	if err := m.checkVersion(ctx); err != nil {
+		return WaitlistEntry{}, err
+	}
+	if !m.groupManager.HasGroupID(ctx, groupID) {
+		return WaitlistEntry{}, ErrGroupIdNotFound
+	}
+
+	var errs SeatErrors
+	idxSet := make(map[int64]struct{})
+	for i, coord := range seats {
+		seat := Seat{GroupID: groupID, Coordinate: coord}
+		if !m.inBound(coord) {
+			errs = append(errs, SeatError{seat, SeatErrorCodeOutOfBound, i})
+			continue
+		}
+
+		idx := coord.AsIndex(m.cfg.NumCols)
+		if !m.seatMap.isSeat(idx) {
+			errs = append(errs, SeatError{seat, SeatErrorCodeNotASeat, i})
+			continue
+		}
+		if _, ok := idxSet[idx]; ok {
+			errs = append(errs, SeatError{seat, SeatErrorCodeDuplicatedPosition, i})
+			continue
+		}
+		idxSet[idx] = struct{}{}
+	}
+	if len(errs) > 0 {
+		return WaitlistEntry{}, errs
+	}
+	if count > m.seatMap.numSeats(m.cfg.NumRows) {
+		return WaitlistEntry{}, ErrNotEnoughSeats
+	}
+
+	entry := &WaitlistEntry{
+		ID:        idutil.New(),
+		GroupID:   groupID,
+		Count:     count,
+		Seats:     slices.Clone(seats),
+		CreatedAt: m.now(),
+	}
+	m.waitlist = append(m.waitlist, entry)
+	m.fillWaitlist(ctx)
+
+	return *entry, nil
+}
+
+// ListWaitlist returns the pending entries in the order they are served.
+func (m *DefaultRoomManager) ListWaitlist(_ context.Context) []WaitlistEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := make([]WaitlistEntry, len(m.waitlist))
+	for i, entry := range m.waitlist {
+		entries[i] = *entry
+	}
+
+	return entries
+}
+
+func (m *DefaultRoomManager) LeaveWaitlist(ctx context.Context, entryID string) error {
+	m.mu.Lock()
+	defer m.unlock()
+	defer m.reportVersion(ctx)
+
+	if err := m.checkVersion(ctx); err != nil {
+		return err
+	}
+
+	i := slices.IndexFunc(m.waitlist, func(entry *WaitlistEntry) bool { return entry.ID == entryID })
+	if i < 0 {
+		return ErrWaitlistEntryNotFound
+	}
+	m.waitlist = slices.Delete(m.waitlist, i, i+1)
+
+	return nil
+}
+
+// dropWaitlist removes every waitlist entry of groupID.
+// The caller must hold m.mu.
+func (m *DefaultRoomManager) dropWaitlist(groupID string) {
+	m.waitlist = slices.DeleteFunc(m.waitlist, func(entry *WaitlistEntry) bool {
+		return entry.GroupID == groupID
+	})
+}
+
+// dropWaitlistEntries removes entries from the waitlist and publishes an
+// event for each of them. The caller must hold m.mu.
+func (m *DefaultRoomManager) dropWaitlistEntries(entries []WaitlistEntry) {
+	for _, entry := range entries {
+		i := slices.IndexFunc(m.waitlist, func(e *WaitlistEntry) bool { return e.ID == entry.ID })
+		if i < 0 {
+			continue
+		}
+		m.waitlist = slices.Delete(m.waitlist, i, i+1)
+
+		m.version++
+		m.publish(Event{Type: EventWaitlistDropped, Waitlist: &entry})
+	}
+}
+
+// fillWaitlist goes through the entries in the order they joined and
+// reserves seats for every one that can be satisfied, publishing an event
+// for each of them. An entry that cannot be satisfied yet keeps its place
+// but does not hold back the later ones.
+// Serving an entry only takes seats, so no earlier entry can become
+// satisfiable and a single pass is enough.
+// The caller must hold m.mu.
+func (m *DefaultRoomManager) fillWaitlist(ctx context.Context) {
+	if len(m.waitlist) == 0 {
+		return
+	}
+
+	ctx = context.WithValue(ctx, ctxkey.Actor{}, waitlistActor)
+	pending := m.waitlist[:0]
+	for _, entry := range m.waitlist {
+		seats := m.waitlistSeats(ctx, entry)
+		if seats == nil {
+			pending = append(pending, entry)
+			continue
+		}
+
+		booking, err := m.book(ctx, seats)
+		m.record(ctx, AuditEntry{Operation: AuditOperationReserve, Seats: seats, BookingID: booking.ID}, err)
+		if err != nil {
+			m.logger.ErrorContext(ctx, "fulfill waitlist entry", "waitlist_id", entry.ID, "error", err)
+			pending = append(pending, entry)
+			continue
+		}
+
+		entry.BookingID = booking.ID
+		fulfilled := *entry
+		m.version++
+		m.publish(Event{Type: EventWaitlistFulfilled, Waitlist: &fulfilled})
+	}
+	clear(m.waitlist[len(pending):])
+	m.waitlist = pending
+}
+
+// fillWaitlistOnSuccess fills the waitlist when *err is nil. It is meant to
+// be deferred by the operations that free seats.
+func (m *DefaultRoomManager) fillWaitlistOnSuccess(ctx context.Context, err *error) {
+	if *err == nil {
+		m.fillWaitlist(ctx)
+	}
+}
+
+// waitlistSeats returns the seats that satisfy entry, or nil when it cannot
+// be satisfied yet. The caller must hold m.mu.
+func (m *DefaultRoomManager) waitlistSeats(ctx context.Context, entry *WaitlistEntry) []Seat {
+	if !m.groupManager.HasGroupID(ctx, entry.GroupID) {
+		return nil
+	}
+
+	if len(entry.Seats) > 0 {
+		seats := make([]Seat, len(entry.Seats))
+		for i, coord := range entry.Seats {
+			seats[i] = Seat{GroupID: entry.GroupID, Coordinate: coord}
+		}
+		if m.validateSeats(ctx, seats) != nil {
+			return nil
+		}
+		return seats
+	}
+
+	return m.allocation(entry.GroupID, entry.Count)
+}
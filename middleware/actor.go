@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/namlh/vulcanLabsOA/consts/ctxkey"
+)
+
+const ActorHeader = "X-Actor"
+
+// Actor stores the caller named by the X-Actor header in the request
+// context. Requests are not authenticated, so the actor is only as
+// trustworthy as the caller.
+func Actor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if actor := r.Header.Get(ActorHeader); actor != "" {
+			r = r.WithContext(context.WithValue(r.Context(), ctxkey.Actor{}, actor))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/namlh/vulcanLabsOA/consts/errcode"
+)
+
+// AdminToken only lets through the requests that send token as a bearer
+// token. Every request is rejected when token is empty, which keeps the
+// admin endpoints disabled until a token is configured.
+func AdminToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			writeError(w, http.StatusForbidden, errcode.Unauthorized, "admin endpoints are disabled")
+			return
+		}
+
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errcode.Unauthorized, "missing or invalid admin token")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,45 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/namlh/vulcanLabsOA/middleware"
+	"github.com/namlh/vulcanLabsOA/testing/assert"
+)
+
+func TestAdminToken(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	do := func(srv *httptest.Server, authorization string) int {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, "POST", srv.URL, nil)
+		assert.NoError(t, err)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		assert.NoError(t, resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	srv := httptest.NewServer(middleware.AdminToken("s3cret", handler))
+	t.Cleanup(srv.Close)
+	assert.Equal(t, http.StatusUnauthorized, do(srv, ""))
+	assert.Equal(t, http.StatusUnauthorized, do(srv, "Bearer wrong"))
+	assert.Equal(t, http.StatusUnauthorized, do(srv, "s3cret"))
+	assert.Equal(t, http.StatusNoContent, do(srv, "Bearer s3cret"))
+
+	disabled := httptest.NewServer(middleware.AdminToken("", handler))
+	t.Cleanup(disabled.Close)
+	assert.Equal(t, http.StatusForbidden, do(disabled, ""))
+	assert.Equal(t, http.StatusForbidden, do(disabled, "Bearer "))
+}
@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/namlh/vulcanLabsOA/consts/errcode"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed from the cache.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	defaultIdempotencyTTL = 24 * time.Hour
+	// maxIdempotentBodySize bounds the body read to fingerprint a request.
+	maxIdempotentBodySize = 1 << 20
+)
+
+// IdempotencyCache remembers the first response for each idempotency key
+// for a fixed time window.
+type IdempotencyCache struct {
+	mu      *sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]*idempotencyEntry
+	// order holds the entries by creation time, which with a fixed ttl is
+	// also the order they expire in. An item whose entry was dropped or
+	// replaced is skipped.
+	order []idempotencyKey
+}
+
+type idempotencyKey struct {
+	key   string
+	entry *idempotencyEntry
+}
+
+type idempotencyEntry struct {
+	fingerprint [sha256.Size]byte
+	expiresAt   time.Time
+	// done is closed once the response is recorded or dropped
+	done     chan struct{}
+	recorded bool
+	status   int
+	header   http.Header
+	body     []byte
+}
+
+func NewIdempotencyCache(ttl time.Duration) *IdempotencyCache {
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+
+	return &IdempotencyCache{
+		mu:      new(sync.Mutex),
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+// acquire returns the entry for key and whether the caller created it, in
+// which case the caller must finish it.
+func (c *IdempotencyCache) acquire(key string, fingerprint [sha256.Size]byte) (*idempotencyEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for len(c.order) > 0 {
+		head := c.order[0]
+		current := c.entries[head.key] == head.entry
+		if current && head.entry.expiresAt.After(now) {
+			break
+		}
+		if current {
+			delete(c.entries, head.key)
+		}
+		c.order = c.order[1:]
+	}
+
+	if entry, ok := c.entries[key]; ok && entry.expiresAt.After(now) {
+		return entry, false
+	}
+
+	entry := &idempotencyEntry{
+		fingerprint: fingerprint,
+		expiresAt:   now.Add(c.ttl),
+		done:        make(chan struct{}),
+	}
+	c.entries[key] = entry
+	c.order = append(c.order, idempotencyKey{key: key, entry: entry})
+
+	return entry, true
+}
+
+// finish records the response of entry, or forgets the key when the
+// response is not worth replaying.
+func (c *IdempotencyCache) finish(key string, entry *idempotencyEntry, rec *bodyRecorder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+		// its item in order is skipped by the sweep
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+	} else {
+		entry.recorded = true
+		entry.status = rec.status
+		entry.header = make(http.Header)
+		for k, v := range rec.Header() {
+			if !slices.Equal(rec.initialHeader[k], v) {
+				entry.header[k] = slices.Clone(v)
+			}
+		}
+		entry.body = rec.body.Bytes()
+	}
+	close(entry.done)
+}
+
+type bodyRecorder struct {
+	http.ResponseWriter
+	// initialHeader holds the headers set before the handler ran, such as
+	// the Request-ID, which belong to the current request only
+	initialHeader http.Header
+	status        int
+	body          bytes.Buffer
+}
+
+func (rw *bodyRecorder) WriteHeader(code int) {
+	if rw.status == 0 {
+		rw.status = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *bodyRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func (rw *bodyRecorder) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.body.Write(b)
+
+	return rw.ResponseWriter.Write(b)
+}
+
+// Idempotency replays the first response recorded for the Idempotency-Key
+// of a request. Keys are scoped to the method and path, and reusing a key
+// with a different body is rejected with 409. Server errors are not
+// recorded so that the request can be retried.
+func Idempotency(logger *slog.Logger, cache *IdempotencyCache, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key = r.Method + " " + r.URL.Path + " " + key
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+		if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, errcode.InvalidParameters, "request body is too large")
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(r.Context(), "read body", "error", err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := sha256.Sum256(body)
+
+		for {
+			entry, created := cache.acquire(key, fingerprint)
+			if created {
+				rec := &bodyRecorder{ResponseWriter: w, initialHeader: w.Header().Clone()}
+				defer cache.finish(key, entry, rec)
+				next.ServeHTTP(rec, r)
+				return
+			}
+
+			if entry.fingerprint != fingerprint {
+				writeError(w, http.StatusConflict, errcode.ResourceConflict, "idempotency key is already used with a different request body")
+				return
+			}
+
+			// wait for a concurrent request with the same key
+			select {
+			case <-entry.done:
+			case <-r.Context().Done():
+				return
+			}
+
+			if entry.recorded {
+				for k, v := range entry.header {
+					w.Header()[k] = v
+				}
+				w.Header().Set(IdempotentReplayedHeader, "true")
+				w.WriteHeader(entry.status)
+				if _, err = w.Write(entry.body); err != nil {
+					logger.ErrorContext(r.Context(), "replay response", "error", err)
+				}
+				return
+			}
+			// the first request failed and its key was dropped, try again
+		}
+	})
+}
+
+func writeError(w http.ResponseWriter, status, code int, msg string) {
+	buf, _ := json.Marshal(struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{code, msg})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(buf)
+}
@@ -0,0 +1,111 @@
+package middleware_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/namlh/vulcanLabsOA/middleware"
+	"github.com/namlh/vulcanLabsOA/testing/assert"
+)
+
+func TestIdempotency(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	var calls int
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(r.URL.Path + ":" + string(body)))
+	})
+	cache := middleware.NewIdempotencyCache(time.Hour)
+	srv := httptest.NewServer(middleware.Idempotency(slog.Default(), cache, handler))
+	t.Cleanup(srv.Close)
+
+	do := func(path, key, body string) (*http.Response, string) {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, "POST", srv.URL+path, strings.NewReader(body))
+		assert.NoError(t, err)
+		if key != "" {
+			req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		buf, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		return resp, string(buf)
+	}
+
+	resp, body := do("/a", "key-1", "first")
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "/a:first", body)
+
+	resp, body = do("/a", "key-1", "first")
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "/a:first", body)
+	assert.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "true", resp.Header.Get(middleware.IdempotentReplayedHeader))
+	assert.Equal(t, 1, calls)
+
+	resp, body = do("/a", "key-1", "second")
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, `{"code":3,"message":"idempotency key is already used with a different request body"}`, body)
+
+	// keys are scoped to the path
+	resp, body = do("/b", "key-1", "second")
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "/b:second", body)
+
+	_, _ = do("/a", "", "first")
+	assert.Equal(t, 3, calls)
+}
+
+func TestIdempotency_Expiry(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) == "fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+	cache := middleware.NewIdempotencyCache(200 * time.Millisecond)
+	srv := httptest.NewServer(middleware.Idempotency(slog.Default(), cache, handler))
+	t.Cleanup(srv.Close)
+
+	do := func(key, body string) int {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, "POST", srv.URL, strings.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// the failed key-1 is retried after key-2, so it outlives key-2
+	assert.Equal(t, http.StatusServiceUnavailable, do("key-1", "fail"))
+	assert.Equal(t, http.StatusCreated, do("key-2", "first"))
+	time.Sleep(120 * time.Millisecond)
+	assert.Equal(t, http.StatusCreated, do("key-1", "first"))
+	time.Sleep(120 * time.Millisecond)
+
+	// key-2 expired, so it is free for another body
+	assert.Equal(t, http.StatusCreated, do("key-2", "second"))
+	assert.Equal(t, http.StatusConflict, do("key-1", "second"))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, do("key-3", strings.Repeat("a", 1<<20+1)))
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/signal"
 	"path"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,6 +20,7 @@ import (
 	"github.com/namlh/vulcanLabsOA/manager"
 	"github.com/namlh/vulcanLabsOA/middleware"
 	"github.com/namlh/vulcanLabsOA/util/fmtutil"
+	"github.com/namlh/vulcanLabsOA/webhook"
 )
 
 func Run(ctx context.Context, getEnv func(key string) string) error {
@@ -36,25 +38,117 @@ func Run(ctx context.Context, getEnv func(key string) string) error {
 	}
 
 	// managers
-	groupManager := manager.NewGroupManager(cfg.Groups)
-	roomManager := manager.NewRoomManager(
+	groupManager, err := manager.NewStoredGroupManager(&cfg.Store, cfg.Groups)
+	if err != nil {
+		return fmt.Errorf("new group manager: %w", err)
+	}
+	defer func() {
+		if err := groupManager.Close(); err != nil {
+			fmtutil.Eprintf("error closing group manager: %s\n", err)
+		}
+	}()
+	eventBus := manager.NewEventBus(cfg.Events.HistorySize)
+
+	var outbox webhook.Outbox = webhook.NewMemoryOutbox()
+	if cfg.Webhooks.OutboxDir != "" {
+		if outbox, err = webhook.NewFileOutbox(cfg.Webhooks.OutboxDir); err != nil {
+			return fmt.Errorf("new webhook outbox: %w", err)
+		}
+	}
+	defer func() {
+		if err := outbox.Close(); err != nil {
+			fmtutil.Eprintf("error closing webhook outbox: %s\n", err)
+		}
+	}()
+	dispatcher, err := webhook.NewDispatcher(logger, cfg.Webhooks, outbox)
+	if err != nil {
+		return fmt.Errorf("new webhook dispatcher: %w", err)
+	}
+
+	eventHistory, err := manager.NewEventHistory(logger, &cfg.History)
+	if err != nil {
+		return fmt.Errorf("new event history: %w", err)
+	}
+	defer func() {
+		if err := eventHistory.Close(); err != nil {
+			fmtutil.Eprintf("error closing event history: %s\n", err)
+		}
+	}()
+
+	auditLog, err := manager.NewAuditLog(&cfg.Audit)
+	if err != nil {
+		return fmt.Errorf("new audit log: %w", err)
+	}
+	defer func() {
+		if err := auditLog.Close(); err != nil {
+			fmtutil.Eprintf("error closing audit log: %s\n", err)
+		}
+	}()
+
+	roomRegistry, err := manager.NewRoomRegistry(
 		logger,
-		&cfg.Room,
+		cfg.Rooms,
+		&cfg.Store,
+		&cfg.Hold,
 		groupManager,
+		manager.EventPublishers{eventHistory, eventBus, dispatcher},
+		auditLog,
 	)
+	if err != nil {
+		return fmt.Errorf("new room registry: %w", err)
+	}
+	defer func() {
+		if err := roomRegistry.Close(); err != nil {
+			fmtutil.Eprintf("error closing room registry: %s\n", err)
+		}
+	}()
+
+	if err := roomRegistry.Restore(ctx); err != nil {
+		return fmt.Errorf("restore rooms: %w", err)
+	}
+
+	// background workers stop with ctx and are joined before the stores
+	// they write to are closed
+	var background sync.WaitGroup
+	defer func() {
+		cancel()
+		background.Wait()
+	}()
+
+	background.Add(2)
+	go func() {
+		defer background.Done()
+		roomRegistry.SweepHolds(ctx, cfg.Hold.SweepInterval)
+	}()
+	go func() {
+		defer background.Done()
+		dispatcher.Run(ctx)
+	}()
 
 	// controllers
-	groupController := controller.NewGroupController(logger, groupManager)
-	roomController := controller.NewRoomController(logger, roomManager)
+	groupController := controller.NewGroupController(logger, groupManager, roomRegistry)
+	roomController := controller.NewRoomController(logger, roomRegistry, groupManager)
+	eventController := controller.NewEventController(logger, roomRegistry, eventBus)
+	webSocketController := controller.NewWebSocketController(logger, roomRegistry, eventBus)
+	auditController := controller.NewAuditController(logger, auditLog)
+	historyController := controller.NewHistoryController(logger, roomRegistry, groupManager, eventHistory)
 
 	srv := NewServer(
 		logger,
+		cfg.Admin.Token,
+		middleware.NewIdempotencyCache(cfg.Idempotency.TTL),
 		roomController,
 		groupController,
+		eventController,
+		webSocketController,
+		auditController,
+		historyController,
 	)
 	httpServer := &http.Server{
 		Addr:    net.JoinHostPort(cfg.Server.Host, cfg.Server.Port),
 		Handler: srv,
+		// cancel long-lived requests such as event streams on shutdown
+		BaseContext: func(net.Listener) context.Context { return ctx },
 	}
 
 	go func() {
@@ -85,8 +179,14 @@ func Run(ctx context.Context, getEnv func(key string) string) error {
 func addRoutes(
 	logger *slog.Logger,
 	mux *http.ServeMux,
+	adminToken string,
+	idempotencyCache *middleware.IdempotencyCache,
 	roomController *controller.RoomController,
 	groupController *controller.GroupController,
+	eventController *controller.EventController,
+	webSocketController *controller.WebSocketController,
+	auditController *controller.AuditController,
+	historyController *controller.HistoryController,
 ) {
 	const apiPathPrefix = "/api/v1"
 
@@ -99,10 +199,35 @@ func addRoutes(
 	handlerConfigs := []HandlerConfig{
 		{"GET", "/health", controller.HealthCheck(logger)},
 		{"GET", "/groups", groupController.ListGroupIDs},
+		{"POST", "/groups", groupController.AddGroup},
+		{"GET", "/groups/{group_id}", groupController.GetGroup},
+		{"DELETE", "/groups/{group_id}", groupController.DeleteGroup},
+		{"GET", "/groups/{group_id}/seats", groupController.ListGroupSeats},
 
-		{"GET", "/available-seats", roomController.ListAvailableSeats},
-		{"POST", "/seats/reservation", roomController.ReserveSeats},
-		{"POST", "/seats/cancellation", roomController.CancelSeats},
+		{"GET", "/rooms", roomController.ListRooms},
+		{"GET", "/rooms/{room_id}/available-seats", roomController.ListAvailableSeats},
+		{"GET", "/rooms/{room_id}/reservations", roomController.ListReservations},
+		{"POST", "/rooms/{room_id}/seats/reservation", roomController.ReserveSeats},
+		{"POST", "/rooms/{room_id}/seats/reservation:validate", roomController.ValidateReservation},
+		{"POST", "/rooms/{room_id}/seats/cancellation", roomController.CancelSeats},
+		{"POST", "/rooms/{room_id}/seats/move", roomController.MoveSeats},
+		{"POST", "/rooms/{room_id}/seats/transaction", roomController.ApplyTransaction},
+		{"POST", "/rooms/{room_id}/seats/allocate", roomController.AllocateSeats},
+		{"POST", "/rooms/{room_id}/seats/hold", roomController.HoldSeats},
+		{"POST", "/rooms/{room_id}/holds/{hold_id}/confirm", roomController.ConfirmHold},
+		{"GET", "/rooms/{room_id}/bookings/{booking_id}", roomController.GetBooking},
+		{"POST", "/rooms/{room_id}/bookings/{booking_id}/cancel", roomController.CancelBooking},
+		{"GET", "/rooms/{room_id}/waitlist", roomController.ListWaitlist},
+		{"POST", "/rooms/{room_id}/waitlist", roomController.JoinWaitlist},
+		{"DELETE", "/rooms/{room_id}/waitlist/{waitlist_id}", roomController.LeaveWaitlist},
+		{"POST", "/admin/rooms/{room_id}/config", roomController.ReconfigureRoom},
+
+		{"GET", "/events", eventController.StreamEvents},
+		{"GET", "/ws", webSocketController.Serve},
+
+		{"GET", "/audit", auditController.ListEntries},
+		{"GET", "/rooms/{room_id}/state", historyController.GetRoomState},
+		{"GET", "/rooms/{room_id}/state/diff", historyController.DiffRoomState},
 	}
 
 	for _, cfg := range handlerConfigs {
@@ -110,25 +235,46 @@ func addRoutes(
 			fmtutil.Eprintf("invalid handler path")
 			os.Exit(1)
 		}
-		mux.Handle(cfg.method+" "+path.Join(apiPathPrefix, cfg.path), cfg.handler)
+
+		var handler http.Handler = cfg.handler
+		if cfg.method == http.MethodPost {
+			handler = middleware.Idempotency(logger, idempotencyCache, handler)
+		}
+		if strings.HasPrefix(cfg.path, "/admin/") {
+			handler = middleware.AdminToken(adminToken, handler)
+		}
+		mux.Handle(cfg.method+" "+path.Join(apiPathPrefix, cfg.path), handler)
 	}
 }
 
 func NewServer(
 	logger *slog.Logger,
+	adminToken string,
+	idempotencyCache *middleware.IdempotencyCache,
 	roomController *controller.RoomController,
 	groupController *controller.GroupController,
+	eventController *controller.EventController,
+	webSocketController *controller.WebSocketController,
+	auditController *controller.AuditController,
+	historyController *controller.HistoryController,
 ) http.Handler {
 	mux := http.NewServeMux()
 	addRoutes(
 		logger,
 		mux,
+		adminToken,
+		idempotencyCache,
 		roomController,
 		groupController,
+		eventController,
+		webSocketController,
+		auditController,
+		historyController,
 	)
 
 	var httpHandler http.Handler = mux
 
+	httpHandler = middleware.Actor(httpHandler)
 	httpHandler = middleware.PanicRecover(logger, httpHandler)
 	httpHandler = middleware.Logging(logger, httpHandler)
 
@@ -0,0 +1,209 @@
+package jsonlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Log is an append-only file with one JSON value per line.
+type Log struct {
+	path string
+	f    *os.File
+	// size is the size of the complete lines of the file
+	size int64
+}
+
+// Open opens the log at path, creating it and its dir when missing,
+// and passes every complete line to decode in order.
+func Open(path string, decode func(line []byte) error) (*Log, error) {
+	return OpenOffsets(path, func(_ int64, line []byte) error {
+		return decode(line)
+	})
+}
+
+// OpenOffsets is Open with the offset of each line passed to decode, to
+// be read again later with ReadLines.
+func OpenOffsets(path string, decode func(offset int64, line []byte) error) (_ *Log, err error) {
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create dir: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, f.Close())
+		}
+	}()
+
+	var validSize int64
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read: %w", err)
+		}
+
+		if err = decode(validSize, bytes.TrimSpace(line)); err != nil {
+			return nil, fmt.Errorf("decode line at offset %d: %w", validSize, err)
+		}
+		validSize += int64(len(line))
+	}
+
+	// drop a line that was only partially written before a crash
+	if err = f.Truncate(validSize); err != nil {
+		return nil, fmt.Errorf("truncate: %w", err)
+	}
+
+	return &Log{path: path, f: f, size: validSize}, nil
+}
+
+// Size returns the offset the next appended line starts at.
+func (l *Log) Size() int64 {
+	return l.size
+}
+
+// Append returns once v is durable. A line that fails to be written is
+// dropped, so that the next one does not follow partial bytes.
+func (l *Log) Append(v any) error {
+	if l.f == nil {
+		return errors.New("log is closed")
+	}
+
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	buf = append(buf, '\n')
+
+	if _, err = l.f.Write(buf); err != nil {
+		return errors.Join(fmt.Errorf("write: %w", err), l.f.Truncate(l.size))
+	}
+	if err = l.f.Sync(); err != nil {
+		return errors.Join(fmt.Errorf("sync: %w", err), l.f.Truncate(l.size))
+	}
+	l.size += int64(len(buf))
+
+	return nil
+}
+
+// ReadLines passes the lines between offset and end, offsets given by
+// OpenOffsets or Size, to f in order until f returns false. It reads the
+// file on its own, so it may run concurrently with Append.
+func (l *Log) ReadLines(offset, end int64, f func(line []byte) (bool, error)) (err error) {
+	file, err := os.Open(l.path)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	defer func() {
+		err = errors.Join(err, file.Close())
+	}()
+
+	reader := bufio.NewReader(io.NewSectionReader(file, offset, end-offset))
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read: %w", err)
+		}
+
+		next, err := f(bytes.TrimSpace(line))
+		if err != nil || !next {
+			return err
+		}
+	}
+}
+
+// Rewrite replaces the content of the log with values. The new content is
+// written to a temporary file that is renamed over the log, so a crash
+// leaves either the old or the new content.
+func (l *Log) Rewrite(values []any) error {
+	if l.f == nil {
+		return errors.New("log is closed")
+	}
+
+	tmpPath := l.path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+
+	var size int64
+	w := bufio.NewWriter(f)
+	for _, v := range values {
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return errors.Join(fmt.Errorf("encode: %w", err), f.Close())
+		}
+		_, _ = w.Write(buf)
+		_ = w.WriteByte('\n')
+		size += int64(len(buf)) + 1
+	}
+	if err = w.Flush(); err != nil {
+		return errors.Join(fmt.Errorf("write: %w", err), f.Close())
+	}
+	if err = f.Sync(); err != nil {
+		return errors.Join(fmt.Errorf("sync: %w", err), f.Close())
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("close: %w", err)
+	}
+
+	if err = os.Rename(tmpPath, l.path); err != nil {
+		return fmt.Errorf("rename: %w", err)
+	}
+	if err = SyncDir(filepath.Dir(l.path)); err != nil {
+		return fmt.Errorf("sync dir: %w", err)
+	}
+
+	// the old file was replaced, appends must go to the new one
+	f, err = os.OpenFile(l.path, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("reopen: %w", err)
+	}
+	err = l.f.Close()
+	l.f = f
+	l.size = size
+	if err != nil {
+		return fmt.Errorf("close replaced file: %w", err)
+	}
+
+	return nil
+}
+
+func (l *Log) Close() error {
+	if l.f == nil {
+		return nil
+	}
+
+	err := l.f.Close()
+	l.f = nil
+
+	return err
+}
+
+// SyncDir makes the entries of dir durable, such as a file renamed into it.
+func SyncDir(dir string) (err error) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, d.Close())
+	}()
+
+	return d.Sync()
+}
@@ -0,0 +1,305 @@
+// Package webhook delivers room events to the configured webhook
+// subscriptions.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/namlh/vulcanLabsOA/config"
+	"github.com/namlh/vulcanLabsOA/manager"
+	"github.com/namlh/vulcanLabsOA/util/idutil"
+)
+
+const (
+	IDHeader        = "X-Webhook-ID"
+	EventHeader     = "X-Webhook-Event"
+	TimestampHeader = "X-Webhook-Timestamp"
+	// SignatureHeader holds "sha256=" followed by the hex HMAC-SHA256 of the
+	// timestamp, a dot and the body, keyed by the subscription secret.
+	SignatureHeader = "X-Webhook-Signature"
+
+	defaultMaxAttempts    = 10
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = 5 * time.Minute
+	defaultTimeout        = 10 * time.Second
+)
+
+// defaultEvents are delivered to a subscription that lists no event types.
+var defaultEvents = []string{manager.EventReservation, manager.EventCancellation, manager.EventChange}
+
+// internalEvents carry the whole state of a room to rebuild it and are
+// never delivered.
+var internalEvents = []string{manager.EventRestore, manager.EventCheckpoint}
+
+// Dispatcher stores a delivery in the outbox for every subscription that
+// matches a published event, then posts the deliveries in the background
+// and retries the failed ones with exponential backoff.
+// Each subscription has its own worker, so a slow receiver only delays its
+// own deliveries, which are posted in the order they were published: a
+// delivery waiting for a retry holds back the later ones. A delivery that
+// fails MaxAttempts times is moved to the dead letters of the outbox.
+type Dispatcher struct {
+	logger *slog.Logger
+	cfg    config.Webhooks
+	outbox Outbox
+	client *http.Client
+	now    func() time.Time
+	// wake holds a channel per subscription id
+	wake map[string]chan struct{}
+}
+
+func NewDispatcher(logger *slog.Logger, cfg config.Webhooks, outbox Outbox) (*Dispatcher, error) {
+	wake := make(map[string]chan struct{}, len(cfg.Subscriptions))
+	for i, sub := range cfg.Subscriptions {
+		if sub.ID == "" {
+			return nil, fmt.Errorf("webhook subscription at index %d: id must not be empty", i)
+		}
+		if _, ok := wake[sub.ID]; ok {
+			return nil, fmt.Errorf("webhook subscription %s: duplicated id", sub.ID)
+		}
+		for _, eventType := range sub.Events {
+			if slices.Contains(internalEvents, eventType) {
+				return nil, fmt.Errorf("webhook subscription %s: %s events are not delivered", sub.ID, eventType)
+			}
+		}
+		wake[sub.ID] = make(chan struct{}, 1)
+	}
+
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaultInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	return &Dispatcher{
+		logger: logger,
+		cfg:    cfg,
+		outbox: outbox,
+		client: &http.Client{Timeout: cfg.Timeout},
+		now:    time.Now,
+		wake:   wake,
+	}, nil
+}
+
+// Publish only stores the deliveries, so it does not wait for receivers.
+func (d *Dispatcher) Publish(event manager.Event) {
+	var payload []byte
+	for _, sub := range d.cfg.Subscriptions {
+		events := sub.Events
+		if len(events) == 0 {
+			events = defaultEvents
+		}
+		if !slices.Contains(events, event.Type) {
+			continue
+		}
+
+		if payload == nil {
+			var err error
+			if payload, err = json.Marshal(event); err != nil {
+				d.logger.Error("encode webhook event", "error", err)
+				return
+			}
+		}
+
+		now := d.now()
+		delivery := Delivery{
+			ID:             idutil.New(),
+			SubscriptionID: sub.ID,
+			URL:            sub.URL,
+			EventType:      event.Type,
+			Payload:        payload,
+			NextAttempt:    now,
+			CreatedAt:      now,
+		}
+		if err := d.outbox.Put(delivery); err != nil {
+			d.logger.Error("store webhook delivery", "subscription_id", sub.ID, "event", event.Type, "error", err)
+			continue
+		}
+
+		select {
+		case d.wake[sub.ID] <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run delivers the pending deliveries until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	d.dropRemoved()
+
+	var wg sync.WaitGroup
+	for _, sub := range d.cfg.Subscriptions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work(ctx, sub)
+		}()
+	}
+	wg.Wait()
+}
+
+// work delivers the pending deliveries of sub until ctx is done.
+func (d *Dispatcher) work(ctx context.Context, sub config.Webhook) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-d.wake[sub.ID]:
+		}
+
+		next := d.deliverDue(ctx, sub)
+		if ctx.Err() != nil {
+			return
+		}
+
+		timer.Stop()
+		if !next.IsZero() {
+			timer.Reset(max(next.Sub(d.now()), 0))
+		}
+	}
+}
+
+// dropRemoved drops the deliveries of subscriptions that are no longer
+// configured.
+func (d *Dispatcher) dropRemoved() {
+	for _, delivery := range d.outbox.Pending("") {
+		if _, ok := d.wake[delivery.SubscriptionID]; ok {
+			continue
+		}
+
+		d.logger.Warn("drop webhook delivery of a removed subscription",
+			"id", delivery.ID, "subscription_id", delivery.SubscriptionID)
+		if err := d.outbox.Remove(delivery.ID); err != nil {
+			d.logger.Error("remove webhook delivery", "id", delivery.ID, "error", err)
+		}
+	}
+}
+
+// deliverDue attempts the deliveries of sub in the order they were
+// published, up to the first one that is not due or fails, and returns when
+// that one is due, or the zero time when none is pending.
+func (d *Dispatcher) deliverDue(ctx context.Context, sub config.Webhook) time.Time {
+	for _, delivery := range d.outbox.Pending(sub.ID) {
+		if ctx.Err() != nil {
+			return time.Time{}
+		}
+
+		if delivery.NextAttempt.After(d.now()) {
+			return delivery.NextAttempt
+		}
+		if retryAt, retry := d.attempt(ctx, sub, delivery); retry {
+			return retryAt
+		}
+	}
+
+	return time.Time{}
+}
+
+// attempt posts delivery once and returns when it should be retried. A
+// delivery interrupted by ctx is retried on the next run.
+func (d *Dispatcher) attempt(ctx context.Context, sub config.Webhook, delivery Delivery) (time.Time, bool) {
+	err := d.post(ctx, sub, delivery)
+	if err == nil {
+		if err = d.outbox.Remove(delivery.ID); err != nil {
+			d.logger.Error("remove webhook delivery", "id", delivery.ID, "error", err)
+		}
+		return time.Time{}, false
+	}
+	if ctx.Err() != nil {
+		return time.Time{}, false
+	}
+
+	delivery.Attempts++
+	if delivery.Attempts >= d.cfg.MaxAttempts {
+		d.logger.Error("dead-letter webhook delivery",
+			"id", delivery.ID, "subscription_id", sub.ID, "attempts", delivery.Attempts, "error", err)
+		if err = d.outbox.DeadLetter(delivery); err != nil {
+			d.logger.Error("dead-letter webhook delivery", "id", delivery.ID, "error", err)
+		}
+		return time.Time{}, false
+	}
+
+	delivery.NextAttempt = d.now().Add(d.backoff(delivery.Attempts))
+	d.logger.Warn("retry webhook delivery",
+		"id", delivery.ID, "subscription_id", sub.ID, "attempts", delivery.Attempts, "next_attempt", delivery.NextAttempt, "error", err)
+	if err = d.outbox.Put(delivery); err != nil {
+		d.logger.Error("store webhook delivery", "id", delivery.ID, "error", err)
+	}
+
+	return delivery.NextAttempt, true
+}
+
+// backoff doubles the initial backoff after each failed attempt.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	backoff := d.cfg.InitialBackoff
+	for i := 1; i < attempts && backoff < d.cfg.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, d.cfg.MaxBackoff)
+}
+
+func (d *Dispatcher) post(ctx context.Context, sub config.Webhook, delivery Delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IDHeader, delivery.ID)
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(TimestampHeader, timestamp)
+	// the secret is taken from the subscription on every attempt so that it
+	// is never written to the outbox
+	if sub.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(sub.Secret, timestamp, delivery.Payload))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Sign returns the value of SignatureHeader for a request.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
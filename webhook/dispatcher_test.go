@@ -0,0 +1,317 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/namlh/vulcanLabsOA/config"
+	"github.com/namlh/vulcanLabsOA/manager"
+	"github.com/namlh/vulcanLabsOA/testing/assert"
+	"github.com/namlh/vulcanLabsOA/webhook"
+)
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// newReceiver fails the first failures requests and then accepts the rest.
+func newReceiver(t *testing.T, failures int) (*httptest.Server, <-chan received) {
+	t.Helper()
+
+	ch := make(chan received, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		ch <- received{header: r.Header.Clone(), body: body}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, ch
+}
+
+func receive(t *testing.T, ch <-chan received) received {
+	t.Helper()
+
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+		return received{}
+	}
+}
+
+func TestDispatcher_Retry(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	srv, ch := newReceiver(t, 2)
+	dispatcher, err := webhook.NewDispatcher(slog.Default(), config.Webhooks{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+		Subscriptions: []config.Webhook{
+			{ID: "seats", URL: srv.URL, Events: []string{manager.EventReservation}, Secret: "s3cret"},
+		},
+	}, webhook.NewMemoryOutbox())
+	assert.NoError(t, err)
+	go dispatcher.Run(ctx)
+
+	dispatcher.Publish(manager.Event{Type: manager.EventCancellation, RoomID: "main", Version: 1})
+	dispatcher.Publish(manager.Event{
+		Type:     manager.EventReservation,
+		RoomID:   "main",
+		Version:  2,
+		Reserved: []manager.Reservation{{Seat: manager.Seat{GroupID: "abc", Coordinate: manager.Coordinate{1, 2}}}},
+	})
+
+	r := receive(t, ch)
+	assert.Equal(t, manager.EventReservation, r.header.Get(webhook.EventHeader))
+	assert.Equal(t, webhook.Sign("s3cret", r.header.Get(webhook.TimestampHeader), r.body), r.header.Get(webhook.SignatureHeader))
+
+	var event manager.Event
+	assert.NoError(t, json.Unmarshal(r.body, &event))
+	assert.Equal(t, uint64(2), event.Version)
+	assert.Equal(t, 1, len(event.Reserved))
+	assert.Equal(t, manager.Coordinate{1, 2}, event.Reserved[0].Coordinate)
+
+	select {
+	case r = <-ch:
+		t.Errorf("unexpected delivery of %s", r.header.Get(webhook.EventHeader))
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDispatcher_DurableOutbox(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	srv, ch := newReceiver(t, 0)
+	cfg := config.Webhooks{
+		Subscriptions: []config.Webhook{{ID: "seats", URL: srv.URL, Secret: "s3cret"}},
+	}
+
+	// published while the dispatcher is not running
+	outbox, err := webhook.NewFileOutbox(dir)
+	assert.NoError(t, err)
+	dispatcher, err := webhook.NewDispatcher(slog.Default(), cfg, outbox)
+	assert.NoError(t, err)
+	dispatcher.Publish(manager.Event{Type: manager.EventReservation, RoomID: "main", Version: 7})
+	assert.NoError(t, outbox.Close())
+
+	outbox, err = webhook.NewFileOutbox(dir)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = outbox.Close() })
+	assert.Equal(t, 1, len(outbox.Pending("")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	dispatcher, err = webhook.NewDispatcher(slog.Default(), cfg, outbox)
+	assert.NoError(t, err)
+	go dispatcher.Run(ctx)
+
+	r := receive(t, ch)
+	assert.Equal(t, manager.EventReservation, r.header.Get(webhook.EventHeader))
+	assert.Equal(t, webhook.Sign("s3cret", r.header.Get(webhook.TimestampHeader), r.body), r.header.Get(webhook.SignatureHeader))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(outbox.Pending("")) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 0, len(outbox.Pending("")))
+}
+
+func TestDispatcher_RemovedSubscription(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	srv, ch := newReceiver(t, 0)
+	outbox := webhook.NewMemoryOutbox()
+	dispatcher, err := webhook.NewDispatcher(slog.Default(), config.Webhooks{
+		Subscriptions: []config.Webhook{{ID: "seats", URL: srv.URL}},
+	}, outbox)
+	assert.NoError(t, err)
+	dispatcher.Publish(manager.Event{Type: manager.EventReservation, RoomID: "main", Version: 1})
+
+	// the subscription is renamed, so its pending delivery is dropped
+	dispatcher, err = webhook.NewDispatcher(slog.Default(), config.Webhooks{
+		Subscriptions: []config.Webhook{{ID: "holds", URL: srv.URL}},
+	}, outbox)
+	assert.NoError(t, err)
+	go dispatcher.Run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(outbox.Pending("")) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 0, len(outbox.Pending("")))
+
+	select {
+	case r := <-ch:
+		t.Errorf("unexpected delivery of %s", r.header.Get(webhook.EventHeader))
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNewDispatcher_SubscriptionID(t *testing.T) {
+	t.Parallel()
+
+	_, err := webhook.NewDispatcher(slog.Default(), config.Webhooks{
+		Subscriptions: []config.Webhook{{URL: "http://localhost"}},
+	}, webhook.NewMemoryOutbox())
+	assert.Equal(t, "webhook subscription at index 0: id must not be empty", err.Error())
+
+	_, err = webhook.NewDispatcher(slog.Default(), config.Webhooks{
+		Subscriptions: []config.Webhook{{ID: "seats", URL: "http://localhost"}, {ID: "seats", URL: "http://localhost"}},
+	}, webhook.NewMemoryOutbox())
+	assert.Equal(t, "webhook subscription seats: duplicated id", err.Error())
+
+	_, err = webhook.NewDispatcher(slog.Default(), config.Webhooks{
+		Subscriptions: []config.Webhook{{ID: "seats", URL: "http://localhost", Events: []string{manager.EventRestore}}},
+	}, webhook.NewMemoryOutbox())
+	assert.Equal(t, "webhook subscription seats: restore events are not delivered", err.Error())
+}
+
+func TestDispatcher_DefaultEvents(t *testing.T) {
+	t.Parallel()
+
+	outbox := webhook.NewMemoryOutbox()
+	dispatcher, err := webhook.NewDispatcher(slog.Default(), config.Webhooks{
+		Subscriptions: []config.Webhook{{ID: "seats", URL: "http://localhost"}},
+	}, outbox)
+	assert.NoError(t, err)
+
+	// only the reservation lifecycle is delivered without a filter
+	for _, eventType := range []string{
+		manager.EventRestore, manager.EventCheckpoint, manager.EventHold, manager.EventWaitlistFulfilled,
+		manager.EventReservation, manager.EventCancellation, manager.EventChange,
+	} {
+		dispatcher.Publish(manager.Event{Type: eventType, RoomID: "main"})
+	}
+
+	delivered := make(map[string]bool)
+	for _, delivery := range outbox.Pending("") {
+		delivered[delivery.EventType] = true
+	}
+	assert.Equal(t, 3, len(delivered))
+	assert.Equal(t, true, delivered[manager.EventReservation])
+	assert.Equal(t, true, delivered[manager.EventCancellation])
+	assert.Equal(t, true, delivered[manager.EventChange])
+}
+
+func TestDispatcher_SlowSubscription(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(release) })
+
+	srv, ch := newReceiver(t, 0)
+	dispatcher, err := webhook.NewDispatcher(slog.Default(), config.Webhooks{
+		Subscriptions: []config.Webhook{
+			{ID: "slow", URL: slow.URL},
+			{ID: "seats", URL: srv.URL},
+		},
+	}, webhook.NewMemoryOutbox())
+	assert.NoError(t, err)
+	go dispatcher.Run(ctx)
+
+	for version := range uint64(3) {
+		dispatcher.Publish(manager.Event{Type: manager.EventReservation, RoomID: "main", Version: version + 1})
+	}
+
+	// the deliveries of seats are neither delayed by slow nor reordered
+	for version := range uint64(3) {
+		var event manager.Event
+		assert.NoError(t, json.Unmarshal(receive(t, ch).body, &event))
+		assert.Equal(t, version+1, event.Version)
+	}
+}
+
+func TestDispatcher_Order(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	srv, ch := newReceiver(t, 2)
+	dispatcher, err := webhook.NewDispatcher(slog.Default(), config.Webhooks{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+		Subscriptions:  []config.Webhook{{ID: "seats", URL: srv.URL}},
+	}, webhook.NewMemoryOutbox())
+	assert.NoError(t, err)
+
+	// the first delivery fails twice and holds back the later ones
+	for version := uint64(1); version <= 3; version++ {
+		dispatcher.Publish(manager.Event{Type: manager.EventReservation, RoomID: "main", Version: version})
+	}
+	go dispatcher.Run(ctx)
+
+	for version := uint64(1); version <= 3; version++ {
+		var event manager.Event
+		assert.NoError(t, json.Unmarshal(receive(t, ch).body, &event))
+		assert.Equal(t, version, event.Version)
+	}
+}
+
+func TestDispatcher_DeadLetter(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	srv, ch := newReceiver(t, 2)
+	outbox, err := webhook.NewFileOutbox(dir)
+	assert.NoError(t, err)
+	dispatcher, err := webhook.NewDispatcher(slog.Default(), config.Webhooks{
+		MaxAttempts:    2,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+		Subscriptions:  []config.Webhook{{ID: "seats", URL: srv.URL}},
+	}, outbox)
+	assert.NoError(t, err)
+
+	dispatcher.Publish(manager.Event{Type: manager.EventReservation, RoomID: "main", Version: 1})
+	dispatcher.Publish(manager.Event{Type: manager.EventReservation, RoomID: "main", Version: 2})
+	go dispatcher.Run(ctx)
+
+	// the first delivery exhausts its attempts, then the second one is posted
+	var event manager.Event
+	assert.NoError(t, json.Unmarshal(receive(t, ch).body, &event))
+	assert.Equal(t, uint64(2), event.Version)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(outbox.Pending("")) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	assert.NoError(t, outbox.Close())
+
+	outbox, err = webhook.NewFileOutbox(dir)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = outbox.Close() })
+	assert.Equal(t, 0, len(outbox.Pending("")))
+
+	deadLetters := outbox.DeadLetters()
+	assert.Equal(t, 1, len(deadLetters))
+	assert.NoError(t, json.Unmarshal(deadLetters[0].Payload, &event))
+	assert.Equal(t, uint64(1), event.Version)
+	assert.Equal(t, 2, deadLetters[0].Attempts)
+}
@@ -0,0 +1,333 @@
+package webhook
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/namlh/vulcanLabsOA/util/jsonlog"
+)
+
+// Delivery is a pending request to a webhook.
+type Delivery struct {
+	ID             string `json:"id"`
+	SubscriptionID string `json:"subscription_id"`
+	// URL is the url of the subscription when the delivery was queued, the
+	// current one is used to post.
+	URL         string          `json:"url"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"next_attempt"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// Outbox stores deliveries until they are done. Put must only return once
+// the delivery survives a restart.
+type Outbox interface {
+	Put(delivery Delivery) error
+	Remove(id string) error
+	// Pending returns the deliveries of a subscription, or of every
+	// subscription when subscriptionID is empty, in the order they were
+	// created.
+	Pending(subscriptionID string) []Delivery
+	// DeadLetter moves a delivery that exhausted its attempts out of the
+	// pending ones and keeps it for inspection.
+	DeadLetter(delivery Delivery) error
+	DeadLetters() []Delivery
+	Close() error
+}
+
+// deliverySet keeps the deliveries of each subscription in the order they
+// were created.
+type deliverySet struct {
+	subscriptions map[string]string
+	deliveries    map[string][]Delivery
+}
+
+func newDeliverySet() *deliverySet {
+	return &deliverySet{
+		subscriptions: make(map[string]string),
+		deliveries:    make(map[string][]Delivery),
+	}
+}
+
+func compareDelivery(a, b Delivery) int {
+	return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+}
+
+func (s *deliverySet) put(delivery Delivery) {
+	s.remove(delivery.ID)
+
+	deliveries := s.deliveries[delivery.SubscriptionID]
+	i, _ := slices.BinarySearchFunc(deliveries, delivery, compareDelivery)
+	s.deliveries[delivery.SubscriptionID] = slices.Insert(deliveries, i, delivery)
+	s.subscriptions[delivery.ID] = delivery.SubscriptionID
+}
+
+func (s *deliverySet) remove(id string) {
+	subscriptionID, ok := s.subscriptions[id]
+	if !ok {
+		return
+	}
+
+	delete(s.subscriptions, id)
+	s.deliveries[subscriptionID] = slices.DeleteFunc(s.deliveries[subscriptionID], func(delivery Delivery) bool {
+		return delivery.ID == id
+	})
+	if len(s.deliveries[subscriptionID]) == 0 {
+		delete(s.deliveries, subscriptionID)
+	}
+}
+
+func (s *deliverySet) len() int {
+	return len(s.subscriptions)
+}
+
+func (s *deliverySet) pending(subscriptionID string) []Delivery {
+	if subscriptionID != "" {
+		return slices.Clone(s.deliveries[subscriptionID])
+	}
+
+	var pending []Delivery
+	for _, deliveries := range s.deliveries {
+		pending = append(pending, deliveries...)
+	}
+	slices.SortFunc(pending, compareDelivery)
+
+	return pending
+}
+
+type MemoryOutbox struct {
+	mu          *sync.Mutex
+	deliveries  *deliverySet
+	deadLetters []Delivery
+}
+
+func NewMemoryOutbox() *MemoryOutbox {
+	return &MemoryOutbox{
+		mu:         new(sync.Mutex),
+		deliveries: newDeliverySet(),
+	}
+}
+
+func (o *MemoryOutbox) Put(delivery Delivery) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.deliveries.put(delivery)
+
+	return nil
+}
+
+func (o *MemoryOutbox) Remove(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.deliveries.remove(id)
+
+	return nil
+}
+
+func (o *MemoryOutbox) Pending(subscriptionID string) []Delivery {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.deliveries.pending(subscriptionID)
+}
+
+func (o *MemoryOutbox) DeadLetter(delivery Delivery) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.deliveries.remove(delivery.ID)
+	o.deadLetters = append(o.deadLetters, delivery)
+
+	return nil
+}
+
+func (o *MemoryOutbox) DeadLetters() []Delivery {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return slices.Clone(o.deadLetters)
+}
+
+func (o *MemoryOutbox) Close() error {
+	return nil
+}
+
+// outboxRecord is a line of the outbox log. A record without a delivery
+// removes the delivery with the id.
+type outboxRecord struct {
+	ID       string    `json:"id"`
+	Delivery *Delivery `json:"delivery,omitempty"`
+}
+
+// The outbox log is rewritten once it holds compactRatio records per
+// pending delivery, and at least compactMinRecords of them, so that the
+// attempts of a delivery that keeps failing do not grow it without bound.
+const (
+	compactMinRecords = 64
+	compactRatio      = 4
+)
+
+// FileOutbox appends every change to a log file. The log is rewritten with
+// only the pending deliveries when it is opened, whenever the outbox
+// becomes empty and whenever most of its records are stale. Dead letters
+// are appended to a log of their own, which is never rewritten.
+type FileOutbox struct {
+	mu         *sync.Mutex
+	log        *jsonlog.Log
+	deadLetter *jsonlog.Log
+	// records counts the records of log
+	records     int
+	deliveries  *deliverySet
+	deadLetters []Delivery
+}
+
+func NewFileOutbox(dir string) (*FileOutbox, error) {
+	o := &FileOutbox{
+		mu:         new(sync.Mutex),
+		deliveries: newDeliverySet(),
+	}
+
+	log, err := jsonlog.Open(filepath.Join(dir, "outbox.log"), func(line []byte) error {
+		var record outboxRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+		if record.Delivery != nil {
+			o.deliveries.put(*record.Delivery)
+		} else {
+			o.deliveries.remove(record.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("open outbox: %w", err)
+	}
+	o.log = log
+
+	deadLetter, err := jsonlog.Open(filepath.Join(dir, "dead_letter.log"), func(line []byte) error {
+		var delivery Delivery
+		if err := json.Unmarshal(line, &delivery); err != nil {
+			return err
+		}
+		o.deadLetters = append(o.deadLetters, delivery)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("open dead letters: %w", err), log.Close())
+	}
+	o.deadLetter = deadLetter
+
+	if err = o.rewrite(); err != nil {
+		return nil, errors.Join(err, log.Close(), deadLetter.Close())
+	}
+
+	return o, nil
+}
+
+// compact rewrites the log when the outbox is empty or when the log holds
+// too many records for the pending deliveries.
+func (o *FileOutbox) compact() error {
+	if live := o.deliveries.len(); live > 0 && o.records < max(compactMinRecords, compactRatio*live) {
+		return nil
+	}
+
+	return o.rewrite()
+}
+
+// rewrite replaces the log with the pending deliveries.
+func (o *FileOutbox) rewrite() error {
+	pending := o.deliveries.pending("")
+	values := make([]any, len(pending))
+	for i, delivery := range pending {
+		values[i] = outboxRecord{ID: delivery.ID, Delivery: &delivery}
+	}
+
+	if err := o.log.Rewrite(values); err != nil {
+		return fmt.Errorf("rewrite outbox: %w", err)
+	}
+	o.records = len(values)
+
+	return nil
+}
+
+func (o *FileOutbox) append(record outboxRecord) error {
+	if err := o.log.Append(record); err != nil {
+		return fmt.Errorf("append outbox: %w", err)
+	}
+	o.records++
+
+	return nil
+}
+
+func (o *FileOutbox) Put(delivery Delivery) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.append(outboxRecord{ID: delivery.ID, Delivery: &delivery}); err != nil {
+		return err
+	}
+	o.deliveries.put(delivery)
+
+	return o.compact()
+}
+
+func (o *FileOutbox) Remove(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.remove(id)
+}
+
+func (o *FileOutbox) remove(id string) error {
+	if err := o.append(outboxRecord{ID: id}); err != nil {
+		return err
+	}
+	o.deliveries.remove(id)
+
+	return o.compact()
+}
+
+func (o *FileOutbox) Pending(subscriptionID string) []Delivery {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.deliveries.pending(subscriptionID)
+}
+
+// DeadLetter writes the dead letter before removing the delivery, so a
+// crash in between leaves the delivery pending rather than lost.
+func (o *FileOutbox) DeadLetter(delivery Delivery) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.deadLetter.Append(delivery); err != nil {
+		return fmt.Errorf("append dead letter: %w", err)
+	}
+	o.deadLetters = append(o.deadLetters, delivery)
+
+	return o.remove(delivery.ID)
+}
+
+func (o *FileOutbox) DeadLetters() []Delivery {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return slices.Clone(o.deadLetters)
+}
+
+func (o *FileOutbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return errors.Join(o.log.Close(), o.deadLetter.Close())
+}
@@ -0,0 +1,75 @@
+package webhook_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/namlh/vulcanLabsOA/testing/assert"
+	"github.com/namlh/vulcanLabsOA/webhook"
+)
+
+func TestFileOutbox_PartialDeadLetter(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	outbox, err := webhook.NewFileOutbox(dir)
+	assert.NoError(t, err)
+	first := webhook.Delivery{ID: "1", SubscriptionID: "seats", CreatedAt: time.Now()}
+	assert.NoError(t, outbox.Put(first))
+	assert.NoError(t, outbox.DeadLetter(first))
+	assert.NoError(t, outbox.Close())
+
+	// simulate a crash in the middle of writing a dead letter
+	f, err := os.OpenFile(filepath.Join(dir, "dead_letter.log"), os.O_WRONLY|os.O_APPEND, 0o644)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"id":"2","subscription_id":"se`)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	outbox, err = webhook.NewFileOutbox(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(outbox.DeadLetters()))
+	third := webhook.Delivery{ID: "3", SubscriptionID: "seats", CreatedAt: time.Now()}
+	assert.NoError(t, outbox.Put(third))
+	assert.NoError(t, outbox.DeadLetter(third))
+	assert.NoError(t, outbox.Close())
+
+	outbox, err = webhook.NewFileOutbox(dir)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = outbox.Close() })
+	deadLetters := outbox.DeadLetters()
+	assert.Equal(t, 2, len(deadLetters))
+	assert.Equal(t, "1", deadLetters[0].ID)
+	assert.Equal(t, "3", deadLetters[1].ID)
+	assert.Equal(t, 0, len(outbox.Pending("")))
+}
+
+func TestFileOutbox_CompactWhilePending(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	outbox, err := webhook.NewFileOutbox(dir)
+	assert.NoError(t, err)
+
+	// a delivery that keeps failing is put again after every attempt
+	delivery := webhook.Delivery{ID: "1", SubscriptionID: "seats", CreatedAt: time.Now()}
+	for attempt := range 1000 {
+		delivery.Attempts = attempt
+		assert.NoError(t, outbox.Put(delivery))
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "outbox.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, true, bytes.Count(content, []byte("\n")) <= 64)
+
+	assert.NoError(t, outbox.Close())
+	outbox, err = webhook.NewFileOutbox(dir)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = outbox.Close() })
+	pending := outbox.Pending("")
+	assert.Equal(t, 1, len(pending))
+	assert.Equal(t, 999, pending[0].Attempts)
+}
@@ -0,0 +1,63 @@
+package websocket_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/namlh/vulcanLabsOA/testing/assert"
+	"github.com/namlh/vulcanLabsOA/websocket"
+)
+
+func TestConn_Echo(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		for {
+			opcode, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err = conn.WriteMessage(opcode, msg); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL)
+	assert.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusUpgradeRequired, resp.StatusCode)
+
+	conn, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"))
+	assert.NoError(t, err)
+
+	// payload lengths that use each of the three length encodings
+	for _, n := range []int{5, 300, 70_000} {
+		msg := strings.Repeat("a", n)
+		assert.NoError(t, conn.WriteMessage(websocket.OpText, []byte(msg)))
+
+		opcode, got, err := conn.ReadMessage()
+		assert.NoError(t, err)
+		assert.Equal(t, websocket.OpText, opcode)
+		assert.Equal(t, msg, string(got))
+	}
+
+	assert.NoError(t, conn.WriteMessage(websocket.OpPing, []byte("ping")))
+	assert.NoError(t, conn.WriteMessage(websocket.OpClose, []byte{0x03, 0xE8}))
+
+	// the pong is swallowed and the close frame is echoed
+	_, _, err = conn.ReadMessage()
+	closeErr := websocket.CloseError{}
+	assert.Equal(t, true, errors.As(err, &closeErr))
+	assert.Equal(t, websocket.CloseNormal, closeErr.Code)
+}